@@ -1,8 +1,9 @@
 package ast
 
 import (
-	"fmt"
+	"reflect"
 
+	"github.com/worldOneo/datapacklang/diag"
 	"github.com/worldOneo/datapacklang/tokens"
 )
 
@@ -18,10 +19,12 @@ type Node interface{}
 type Scoped struct {
 	Prefix string
 	Body   Block
+	Pos    tokens.Position
 }
 
 type String struct {
 	Value string
+	Pos   tokens.Position
 }
 
 type Float struct {
@@ -38,6 +41,9 @@ type Block struct {
 
 type CreateStore struct {
 	Identifier string
+	// Doc is the text of the /// comments in front of the declaration
+	Doc string
+	Pos tokens.Position
 }
 
 type Calculation struct {
@@ -49,6 +55,7 @@ type Calculation struct {
 type As struct {
 	Selector string
 	Body     Block
+	Pos      tokens.Position
 }
 
 type If struct {
@@ -57,6 +64,33 @@ type If struct {
 	Second     Node
 	Not        bool
 	Body       Block
+	// Else is either nil, a Block or an If for else if chains
+	Else Node
+	Pos  tokens.Position
+}
+
+type While struct {
+	First      Node
+	Comparator tokens.OperationType
+	Second     Node
+	Not        bool
+	Body       Block
+	Pos        tokens.Position
+}
+
+// For runs the body for every value of Variable from Start up to, but excluding, End.
+type For struct {
+	Variable string
+	Start    Node
+	End      Node
+	Body     Block
+	Pos      tokens.Position
+}
+
+type Repeat struct {
+	Count Node
+	Body  Block
+	Pos   tokens.Position
 }
 
 type Index struct {
@@ -69,6 +103,7 @@ type StoreAssign struct {
 	Store      string
 	Operation  tokens.OperationType
 	Value      Node
+	Pos        tokens.Position
 }
 
 type StoreAccess struct {
@@ -79,43 +114,180 @@ type StoreAccess struct {
 type Expression struct {
 	Identifier string
 	ArgList    []Node
+	Pos        tokens.Position
+}
+
+// Variable references a named value like a function parameter.
+type Variable struct {
+	Identifier string
+}
+
+type Func struct {
+	Identifier string
+	Parameters []string
+	Body       Block
+	// Doc is the text of the /// comments in front of the declaration
+	Doc string
+	Pos tokens.Position
+}
+
+type Return struct {
+	Value Node
+	Pos   tokens.Position
+}
+
+// PositionOf returns the position of a statement in the source code.
+// Nodes created by the compiler and values have no known position.
+func PositionOf(node Node) tokens.Position {
+	switch n := node.(type) {
+	case Scoped:
+		return n.Pos
+	case String:
+		return n.Pos
+	case CreateStore:
+		return n.Pos
+	case As:
+		return n.Pos
+	case If:
+		return n.Pos
+	case While:
+		return n.Pos
+	case For:
+		return n.Pos
+	case Repeat:
+		return n.Pos
+	case StoreAssign:
+		return n.Pos
+	case Expression:
+		return n.Pos
+	case Func:
+		return n.Pos
+	case Return:
+		return n.Pos
+	}
+	return tokens.Position{}
+}
+
+// WithoutPositions returns a copy of the tree with every position reset,
+// so trees of differently formatted code can be compared.
+func WithoutPositions(node Node) Node {
+	if node == nil {
+		return nil
+	}
+	return strip(reflect.ValueOf(node)).Interface()
+}
+
+func strip(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(tokens.Position{}) {
+			return reflect.Zero(value.Type())
+		}
+		stripped := reflect.New(value.Type()).Elem()
+		for i := 0; i < value.NumField(); i++ {
+			stripped.Field(i).Set(strip(value.Field(i)))
+		}
+		return stripped
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		stripped := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			stripped.Index(i).Set(strip(value.Index(i)))
+		}
+		return stripped
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		stripped := reflect.New(value.Type()).Elem()
+		stripped.Set(strip(value.Elem()))
+		return stripped
+	}
+	return value
 }
 
 type Program = Block
 
 type Parser struct {
-	tokens []tokens.Token
-	index  int
+	tokens      []tokens.Token
+	index       int
+	diagnostics diag.List
 }
 
+// Parse builds the tree of the program, the returned error is a diag.List.
+// Statements which can't be parsed are skipped, so the program is returned
+// together with the errors of every statement.
 func Parse(lexed []tokens.Token) (Node, error) {
 	parser := Parser{
 		tokens: lexed,
 	}
-	return parser.parse()
+	program, err := parser.parse()
+	if err != nil {
+		return program, err
+	}
+	return program, parser.diagnostics.Err()
+}
+
+// errorf reports an error at the token or at the end of the program if the token is missing.
+func (P *Parser) errorf(token tokens.Token, code diag.Code, format string, args ...interface{}) error {
+	span := token.Span
+	if span.Start.Line == 0 && len(P.tokens) > 0 {
+		span = diag.Point(P.tokens[len(P.tokens)-1].Span.End)
+	}
+	return diag.Errorf(code, span, format, args...)
+}
+
+// expected reports that the token was found instead of an expected one.
+// The token isn't part of the statement, if it was consumed it is given back.
+// If it is missing or on a later line the error is placed directly behind the last token of the statement.
+func (P *Parser) expected(token tokens.Token, code diag.Code, format string, args ...interface{}) error {
+	if token.Span.Start.Line > 0 && P.index > 0 && P.tokens[P.index-1].Span == token.Span {
+		P.index--
+	}
+	if P.index == 0 {
+		return P.errorf(token, code, format, args...)
+	}
+	previous := P.tokens[P.index-1]
+	span := token.Span
+	if span.Start.Line == 0 || span.Start.Line > previous.Span.End.Line {
+		span = diag.Point(previous.Span.End)
+	}
+	return diag.Errorf(code, span, format, args...)
 }
 
 func (P *Parser) parse() (Node, error) {
 	l := 64
 	body := make([]Node, l)
 	bindex := 0
-	peek, peeked := P.peek()
-	returnOnScopeClose := peeked && peek.Type == tokens.ScopeOpen
+	open, peeked := P.peek()
+	returnOnScopeClose := peeked && open.Type == tokens.ScopeOpen
 
 	if returnOnScopeClose {
 		P.next()
 	}
 
+	closed := !returnOnScopeClose
 	for P.index < len(P.tokens) {
 		if returnOnScopeClose {
-			peek, peeked = P.peek()
+			peek, peeked := P.peek()
 			if peeked && peek.Type == tokens.ScopeClosed {
 				P.next()
+				closed = true
 				break
 			}
 		}
+		start := P.index
 		node, err := P.pullValue()
-		if err != nil {
+		if err == nil && !isStatement(node) {
+			err = P.errorf(P.tokens[start], diag.ExpectedToken, "Statement expected at '%s'", P.tokens[start].Content)
+		}
+		if list, ok := diag.From(err); ok {
+			P.diagnostics = append(P.diagnostics, list...)
+			P.synchronize(start, returnOnScopeClose)
+			continue
+		} else if err != nil {
 			return nil, err
 		}
 		body[bindex] = node
@@ -127,9 +299,76 @@ func (P *Parser) parse() (Node, error) {
 			copy(body, old)
 		}
 	}
+	if !closed {
+		P.diagnostics = append(P.diagnostics, diag.Errorf(diag.ExpectedToken, open.Span, "Unclosed block"))
+	}
 	return Block{body[0:bindex]}, nil
 }
 
+// isStatement reports whether the node does something on its own,
+// values which are only computed, like a bare variable, aren't statements.
+func isStatement(node Node) bool {
+	switch node.(type) {
+	case Int, Float, Variable, StoreAccess, Calculation:
+		return false
+	}
+	return true
+}
+
+// statements are the tokens which start a statement.
+var statements = map[tokens.TokenType]bool{
+	tokens.Create: true,
+	tokens.If:     true,
+	tokens.While:  true,
+	tokens.As:     true,
+	tokens.For:    true,
+	tokens.Repeat: true,
+	tokens.Func:   true,
+	tokens.Return: true,
+}
+
+// synchronize skips the rest of the statement starting at start which failed.
+// Parsing continues at the next token starting a statement or a line after the last consumed token,
+// or at the } closing the block. Blocks inside the skipped statement are skipped as a whole.
+func (P *Parser) synchronize(start int, nested bool) {
+	if P.index == start {
+		P.next()
+	}
+	line := P.tokens[P.index-1].Span.End.Line
+	for {
+		peek, peeked := P.peek()
+		switch {
+		case !peeked || statements[peek.Type] || peek.Line() > line:
+			return
+		case peek.Type == tokens.ScopeClosed:
+			if !nested {
+				P.next()
+			}
+			return
+		case peek.Type == tokens.ScopeOpen:
+			P.skipBlock()
+		default:
+			P.next()
+		}
+	}
+}
+
+// skipBlock skips the block starting at the current token including its nested blocks.
+func (P *Parser) skipBlock() {
+	depth := 0
+	for token, ok := P.next(); ok; token, ok = P.next() {
+		switch token.Type {
+		case tokens.ScopeOpen:
+			depth++
+		case tokens.ScopeClosed:
+			depth--
+		}
+		if depth == 0 {
+			return
+		}
+	}
+}
+
 func (P *Parser) peek() (tokens.Token, bool) {
 	if P.index < len(P.tokens) {
 		return P.tokens[P.index], true
@@ -155,9 +394,9 @@ func (P *Parser) argList() ([]Node, error) {
 			P.next()
 			continue
 		} else if requiresComma || peek.Type == tokens.Comma {
-			return nil, fmt.Errorf("unexpected comma")
+			return nil, P.expected(peek, diag.ExpectedToken, "Unexpected comma")
 		}
-		arg, err := P.pullValue()
+		arg, err := P.expression(lowestPrecedence)
 		requiresComma = true
 		if err != nil {
 			return nil, err
@@ -168,60 +407,157 @@ func (P *Parser) argList() ([]Node, error) {
 	return args, nil
 }
 
-func (P *Parser) pullValue() (Node, error) {
+// Binding strength of the binary operators, higher binds tighter.
+const (
+	lowestPrecedence = iota + 1
+	additivePrecedence
+	multiplicativePrecedence
+)
+
+var precedence = map[tokens.OperationType]int{
+	tokens.OperationAdd: additivePrecedence,
+	tokens.OperationSub: additivePrecedence,
+	tokens.OperationMul: multiplicativePrecedence,
+	tokens.OperationDiv: multiplicativePrecedence,
+	tokens.OperationMod: multiplicativePrecedence,
+}
+
+// expression parses a calculation using precedence climbing.
+// Only operators binding at least as strong as minPrecedence are consumed.
+func (P *Parser) expression(minPrecedence int) (Node, error) {
+	left, err := P.unary()
+	if err != nil {
+		return nil, err
+	}
+	return P.binary(left, minPrecedence)
+}
+
+// binary continues an expression of which the left operand was already parsed.
+// Operators of equal precedence associate to the left.
+func (P *Parser) binary(left Node, minPrecedence int) (Node, error) {
+	for {
+		operator, ok := P.peek()
+		if !ok || operator.Type != tokens.Operation {
+			return left, nil
+		}
+		strength, ok := precedence[operator.ValueInt]
+		if !ok || strength < minPrecedence {
+			return left, nil
+		}
+		P.next()
+		right, err := P.expression(strength + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = Calculation{left, operator.ValueInt, right}
+	}
+}
+
+func (P *Parser) unary() (Node, error) {
 	next, has := P.next()
 	if !has {
-		return nil, fmt.Errorf("Expected value")
+		return nil, P.expected(next, diag.ExpectedValue, "Expected value")
 	}
 
-	peek, peeked := P.peek()
 	switch next.Type {
+	case tokens.Operation:
+		if next.ValueInt != tokens.OperationSub {
+			break
+		}
+		value, err := P.unary()
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := value.(Int); ok {
+			return Int{-i.Value}, nil
+		}
+		return Calculation{Int{0}, tokens.OperationSub, value}, nil
+	case tokens.ParenOpen:
+		value, err := P.expression(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		closing, ok := P.next()
+		if !ok || closing.Type != tokens.ParenClosed {
+			return nil, P.expected(closing, diag.ExpectedToken, "Closing parenthesis expected")
+		}
+		return value, nil
 	case tokens.Identifier:
+		peek, _ := P.peek()
 		if peek.Type == tokens.ParenOpen {
 			P.next()
 			args, err := P.argList()
 			if err != nil {
 				return nil, err
 			}
-			return Expression{next.Content, args}, nil
+			return Expression{next.Content, args, next.Position()}, nil
 		} else if peek.Type == tokens.IndexOpen {
-			P.next()
-			identifier, ok := P.next()
-			if !ok || (identifier.Type != tokens.Identifier && identifier.Type != tokens.String) {
-				break
-			}
-			isVar := identifier.Type == tokens.Identifier
-			closedIndex, ok := P.next()
-			if !ok || closedIndex.Type != tokens.IndexClosed {
-				break
-			}
-			operation, ok := P.peek()
-			if !ok || operation.Type != tokens.OperationAssignment {
-				if ok && operation.Type == tokens.Operation {
-					P.next()
-					value, err := P.pullValue()
-					if err != nil {
-						return nil, err
-					}
-					return Calculation{StoreAccess{Index{identifier.Content, isVar}, next.Content}, operation.ValueInt, value}, nil
-				}
-				return StoreAccess{Index{identifier.Content, isVar}, next.Content}, nil
-			}
-			P.next()
-			if operation.ValueInt == tokens.OperationInc {
-				return StoreAssign{Index{identifier.Content, isVar}, next.Content, tokens.OperationAdd, Int{1}}, nil
-			}
+			return P.storeAccess(next)
+		}
+		return Variable{next.Content}, nil
+	case tokens.Float:
+		return Float{next.ValueFloat}, nil
+	case tokens.Integer:
+		return Int{next.ValueInt}, nil
+	case tokens.String:
+		return String{next.Content, next.Position()}, nil
+	}
+	return nil, P.expected(next, diag.ExpectedValue, "Value expected at '%s'", next.Content)
+}
 
-			if operation.ValueInt == tokens.OperationDec {
-				return StoreAssign{Index{identifier.Content, isVar}, next.Content, tokens.OperationSub, Int{1}}, nil
-			}
-			value, err := P.pullValue()
-			if err != nil {
-				return nil, err
-			}
-			return StoreAssign{Index{identifier.Content, isVar}, next.Content, operation.ValueInt, value}, nil
+// storeAccess parses the index following the store identifier.
+func (P *Parser) storeAccess(store tokens.Token) (StoreAccess, error) {
+	P.next()
+	identifier, ok := P.next()
+	if !ok || (identifier.Type != tokens.Identifier && identifier.Type != tokens.String) {
+		return StoreAccess{}, P.expected(identifier, diag.ExpectedToken, "Index expected")
+	}
+	isVar := identifier.Type == tokens.Identifier
+	closedIndex, ok := P.next()
+	if !ok || closedIndex.Type != tokens.IndexClosed {
+		return StoreAccess{}, P.expected(closedIndex, diag.ExpectedToken, "Closing index expected")
+	}
+	return StoreAccess{Index{identifier.Content, isVar}, store.Content}, nil
+}
+
+func (P *Parser) pullValue() (Node, error) {
+	next, has := P.peek()
+	if !has {
+		return nil, P.expected(next, diag.ExpectedValue, "Expected value")
+	}
+
+	switch next.Type {
+	case tokens.Identifier:
+		P.next()
+		peek, _ := P.peek()
+		if peek.Type != tokens.IndexOpen {
+			P.index--
+			return P.expression(lowestPrecedence)
+		}
+		access, err := P.storeAccess(next)
+		if err != nil {
+			return nil, err
+		}
+		operation, ok := P.peek()
+		if !ok || operation.Type != tokens.OperationAssignment {
+			return P.binary(access, lowestPrecedence)
+		}
+		P.next()
+		if operation.ValueInt == tokens.OperationInc {
+			return StoreAssign{access.Identifier, access.Store, tokens.OperationAdd, Int{1}, next.Position()}, nil
+		}
+
+		if operation.ValueInt == tokens.OperationDec {
+			return StoreAssign{access.Identifier, access.Store, tokens.OperationSub, Int{1}, next.Position()}, nil
+		}
+		value, err := P.expression(lowestPrecedence)
+		if err != nil {
+			return nil, err
 		}
+		return StoreAssign{access.Identifier, access.Store, operation.ValueInt, value, next.Position()}, nil
 	case tokens.Create:
+		P.next()
+		peek, _ := P.peek()
 		if peek.Type != tokens.Identifier {
 			break
 		}
@@ -231,73 +567,207 @@ func (P *Parser) pullValue() (Node, error) {
 			break
 		}
 		if peek.Content == "store" {
-			return CreateStore{name.Content}, nil
-		}
-	case tokens.Float:
-		return Float{next.ValueFloat}, nil
-	case tokens.Integer:
-		if peeked && peek.Type == tokens.Operation {
-			P.next()
-			value, err := P.pullValue()
-			if err != nil {
-				return nil, err
-			}
-			return Calculation{Int{next.ValueInt}, peek.ValueInt, value}, nil
+			return CreateStore{name.Content, next.Doc, next.Position()}, nil
 		}
-		return Int{next.ValueInt}, nil
 	case tokens.String:
+		P.next()
+		peek, peeked := P.peek()
 		if peeked && peek.Type == tokens.ScopeOpen {
 			body, err := P.parse()
 			if err != nil {
 				return nil, err
 			}
 			if _, ok := body.(Block); !ok {
-				return nil, fmt.Errorf("Prefixed scopes require a block line: %d", peek.Line)
+				return nil, P.errorf(peek, diag.MissingBody, "Prefixed scopes require a block")
 			}
-			return Scoped{next.Content, body.(Block)}, nil
+			return Scoped{next.Content, body.(Block), next.Position()}, nil
 		}
-		return String{next.Content}, nil
+		P.index--
+		return P.expression(lowestPrecedence)
 	case tokens.If:
-		not := false
-		if peek.Type == tokens.Not {
-			not = true
-			P.next()
-		}
-		first, err := P.pullValue()
+		P.next()
+		first, comparator, second, not, err := P.condition()
 		if err != nil {
 			return nil, err
 		}
-		comparator, ok := P.next()
-		if !ok || comparator.Type != tokens.OperationComp {
-			return nil, fmt.Errorf("Comparator expected")
+		body, err := P.body("If", next)
+		if err != nil {
+			return nil, err
 		}
-		second, err := P.pullValue()
+		otherwise, err := P._else()
 		if err != nil {
 			return nil, err
 		}
-		body, err := P.parse()
+		return If{first, comparator, second, not, body, otherwise, next.Position()}, nil
+	case tokens.While:
+		P.next()
+		first, comparator, second, not, err := P.condition()
 		if err != nil {
 			return nil, err
 		}
-		if _, ok := body.(Block); !ok {
-			return nil, fmt.Errorf("If requires body line: %d", next.Line)
+		body, err := P.body("While", next)
+		if err != nil {
+			return nil, err
 		}
-		return If{first, comparator.ValueInt, second, not, body.(Block)}, nil
+		return While{first, comparator, second, not, body, next.Position()}, nil
 	case tokens.As:
+		P.next()
+		peek, _ := P.peek()
 		if peek.Type != tokens.String {
-			return nil, fmt.Errorf("As requires selector line: %d", next.Line)
+			return nil, P.expected(peek, diag.ExpectedToken, "As requires selector")
 		}
 		P.next()
-		body, err := P.parse()
+		body, err := P.body("As", next)
 		if err != nil {
 			return nil, err
 		}
-		if _, ok := body.(Block); !ok {
-			return nil, fmt.Errorf("As requires body line: %d", next.Line)
+		return As{peek.Content, body, next.Position()}, nil
+	case tokens.For:
+		P.next()
+		variable, ok := P.next()
+		if !ok || variable.Type != tokens.Identifier {
+			return nil, P.expected(variable, diag.ExpectedToken, "For requires variable")
+		}
+		in, ok := P.next()
+		if !ok || in.Type != tokens.In {
+			return nil, P.expected(in, diag.ExpectedToken, "For requires in")
 		}
-		return As{peek.Content, body.(Block)}, nil
+		start, err := P.expression(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		rangeOperator, ok := P.next()
+		if !ok || rangeOperator.Type != tokens.Range {
+			return nil, P.expected(rangeOperator, diag.ExpectedToken, "For requires range")
+		}
+		end, err := P.expression(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		body, err := P.body("For", next)
+		if err != nil {
+			return nil, err
+		}
+		return For{variable.Content, start, end, body, next.Position()}, nil
+	case tokens.Repeat:
+		P.next()
+		count, err := P.expression(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		body, err := P.body("Repeat", next)
+		if err != nil {
+			return nil, err
+		}
+		return Repeat{count, body, next.Position()}, nil
+	case tokens.Func:
+		P.next()
+		name, ok := P.next()
+		if !ok || name.Type != tokens.Identifier {
+			return nil, P.expected(name, diag.ExpectedToken, "Func requires name")
+		}
+		parameters, err := P.parameters()
+		if err != nil {
+			return nil, err
+		}
+		body, err := P.body("Func", next)
+		if err != nil {
+			return nil, err
+		}
+		return Func{name.Content, parameters, body, next.Doc, next.Position()}, nil
+	case tokens.Return:
+		P.next()
+		value, err := P.expression(lowestPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		return Return{value, next.Position()}, nil
+	default:
+		return P.expression(lowestPrecedence)
+	}
+	return nil, P.errorf(next, diag.ExpectedToken, "Identifier expected at '%s'", next.Content)
+}
+
+// parameters parses the parenthesized parameter names of a func declaration.
+func (P *Parser) parameters() ([]string, error) {
+	open, ok := P.next()
+	if !ok || open.Type != tokens.ParenOpen {
+		return nil, P.expected(open, diag.ExpectedToken, "Parameter list expected")
+	}
+	parameters := make([]string, 0)
+	for {
+		name, ok := P.next()
+		if !ok {
+			return nil, P.errorf(open, diag.ExpectedToken, "Unclosed parameter list")
+		}
+		if name.Type == tokens.ParenClosed && len(parameters) == 0 {
+			return parameters, nil
+		}
+		if name.Type != tokens.Identifier {
+			return nil, P.expected(name, diag.ExpectedToken, "Parameter name expected at '%s'", name.Content)
+		}
+		parameters = append(parameters, name.Content)
+		separator, ok := P.next()
+		if ok && separator.Type == tokens.ParenClosed {
+			return parameters, nil
+		}
+		if !ok || separator.Type != tokens.Comma {
+			return nil, P.expected(separator, diag.ExpectedToken, "Comma expected")
+		}
+	}
+}
+
+// body parses the block of a statement.
+func (P *Parser) body(name string, statement tokens.Token) (Block, error) {
+	peek, peeked := P.peek()
+	if !peeked || peek.Type != tokens.ScopeOpen {
+		return Block{}, P.errorf(statement, diag.MissingBody, "%s requires body", name)
+	}
+	body, err := P.parse()
+	if err != nil {
+		return Block{}, err
+	}
+	return body.(Block), nil
+}
+
+// condition parses an optionally negated comparison of two values.
+func (P *Parser) condition() (Node, tokens.OperationType, Node, bool, error) {
+	peek, _ := P.peek()
+	not := false
+	if peek.Type == tokens.Not {
+		not = true
+		P.next()
+	}
+	first, err := P.expression(lowestPrecedence)
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+	comparator, ok := P.next()
+	if !ok || comparator.Type != tokens.OperationComp {
+		return nil, 0, nil, false, P.expected(comparator, diag.ExpectedToken, "Comparator expected")
+	}
+	second, err := P.expression(lowestPrecedence)
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+	return first, comparator.ValueInt, second, not, nil
+}
+
+// _else parses an optional else branch following the body of an if.
+func (P *Parser) _else() (Node, error) {
+	peek, peeked := P.peek()
+	if !peeked || peek.Type != tokens.Else {
+		return nil, nil
+	}
+	P.next()
+	branch, peeked := P.peek()
+	if peeked && branch.Type == tokens.If {
+		return P.pullValue()
+	}
+	if !peeked || branch.Type != tokens.ScopeOpen {
+		return nil, P.errorf(peek, diag.MissingBody, "Else requires body")
 	}
-	return nil, fmt.Errorf("Identifier Expected line: %d at '%s'", next.Line, next.Content)
+	return P.parse()
 }
 
 func MakeStoreAccess(store, identifier string, isVar bool) StoreAccess {
@@ -4,9 +4,13 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/worldOneo/datapacklang/diag"
 	"github.com/worldOneo/datapacklang/tokens"
 )
 
+// none is the position of nodes in expected trees, positions are only compared by TestParse_Positions.
+var none tokens.Position
+
 func TestParse(t *testing.T) {
 	type args struct {
 		lexed []tokens.Token
@@ -74,13 +78,235 @@ func TestParse(t *testing.T) {
 				[]Node{
 					If{Int{1}, tokens.OperationLt, Int{2}, false, Block{
 						[]Node{
-							String{"say hi"},
+							String{Value: "say hi"},
 						},
-					}},
+					}, nil, none},
+				},
+			},
+			false,
+		},
+		{
+			"precedence",
+			args{tokens.Lexerp(`
+				a[b] = 10*10+10
+				a[b] = 1+2*3
+				a[b] = 1-2%3/4
+			`)},
+			Block{
+				[]Node{
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{Calculation{Int{10}, tokens.OperationMul, Int{10}}, tokens.OperationAdd, Int{10}}),
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{Int{1}, tokens.OperationAdd, Calculation{Int{2}, tokens.OperationMul, Int{3}}}),
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{Int{1}, tokens.OperationSub, Calculation{Calculation{Int{2}, tokens.OperationMod, Int{3}}, tokens.OperationDiv, Int{4}}}),
 				},
 			},
 			false,
 		},
+		{
+			"left associativity",
+			args{tokens.Lexerp(`
+				a[b] = 1-2-3
+				a[b] = 8/4*2
+			`)},
+			Block{
+				[]Node{
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{Calculation{Int{1}, tokens.OperationSub, Int{2}}, tokens.OperationSub, Int{3}}),
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{Calculation{Int{8}, tokens.OperationDiv, Int{4}}, tokens.OperationMul, Int{2}}),
+				},
+			},
+			false,
+		},
+		{
+			"parentheses",
+			args{tokens.Lexerp(`a[b] = (1+2)*(c[d]-(3))`)},
+			Block{
+				[]Node{
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{
+						Calculation{Int{1}, tokens.OperationAdd, Int{2}},
+						tokens.OperationMul,
+						Calculation{MakeStoreAccess("c", "d", true), tokens.OperationSub, Int{3}},
+					}),
+				},
+			},
+			false,
+		},
+		{
+			"unary minus",
+			args{tokens.Lexerp(`
+				a[b] = -5
+				a[b] = -c[d] * -2
+				a[b] = -(1+2)
+			`)},
+			Block{
+				[]Node{
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Int{-5}),
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{Calculation{Int{0}, tokens.OperationSub, MakeStoreAccess("c", "d", true)}, tokens.OperationMul, Int{-2}}),
+					MakeStoreAssign("a", "b", true, tokens.OperationSet, Calculation{Int{0}, tokens.OperationSub, Calculation{Int{1}, tokens.OperationAdd, Int{2}}}),
+				},
+			},
+			false,
+		},
+		{
+			"if calculations",
+			args{tokens.Lexerp("if a[b] * 2 > c[d] + 1 { 'say hi' }")},
+			Block{
+				[]Node{
+					If{
+						Calculation{MakeStoreAccess("a", "b", true), tokens.OperationMul, Int{2}},
+						tokens.OperationGt,
+						Calculation{MakeStoreAccess("c", "d", true), tokens.OperationAdd, Int{1}},
+						false,
+						Block{[]Node{String{Value: "say hi"}}},
+						nil,
+						none,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"else",
+			args{tokens.Lexerp(`
+				if a[b] == 1 {
+					'say one'
+				} else {
+					'say other'
+				}
+			`)},
+			Block{
+				[]Node{
+					If{MakeStoreAccess("a", "b", true), tokens.OperationEq, Int{1}, false,
+						Block{[]Node{String{Value: "say one"}}},
+						Block{[]Node{String{Value: "say other"}}},
+						none,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"else if",
+			args{tokens.Lexerp(`
+				if a[b] == 1 {
+					'say one'
+				} else if not a[b] > 2 {
+					'say two'
+				} else {
+					'say other'
+				}
+				'say done'
+			`)},
+			Block{
+				[]Node{
+					If{MakeStoreAccess("a", "b", true), tokens.OperationEq, Int{1}, false,
+						Block{[]Node{String{Value: "say one"}}},
+						If{MakeStoreAccess("a", "b", true), tokens.OperationGt, Int{2}, true,
+							Block{[]Node{String{Value: "say two"}}},
+							Block{[]Node{String{Value: "say other"}}},
+							none,
+						},
+						none,
+					},
+					String{Value: "say done"},
+				},
+			},
+			false,
+		},
+		{
+			"else without body",
+			args{tokens.Lexerp(`if a[b] == 1 { 'say one' } else 'say other'`)},
+			Block{[]Node{}},
+			true,
+		},
+		{
+			"func",
+			args{tokens.Lexerp(`
+				func add(a, b) {
+					return a + b
+				}
+				func hello() { 'say hello' }
+				s[x] = add(s[y], 2) * 3
+				hello()
+			`)},
+			Block{
+				[]Node{
+					Func{"add", []string{"a", "b"}, Block{[]Node{
+						Return{Calculation{Variable{"a"}, tokens.OperationAdd, Variable{"b"}}, none},
+					}}, "", none},
+					Func{"hello", []string{}, Block{[]Node{String{Value: "say hello"}}}, "", none},
+					MakeStoreAssign("s", "x", true, tokens.OperationSet, Calculation{
+						Expression{"add", []Node{MakeStoreAccess("s", "y", true), Int{2}}, none},
+						tokens.OperationMul,
+						Int{3},
+					}),
+					Expression{"hello", []Node{}, none},
+				},
+			},
+			false,
+		},
+		{
+			"func without body",
+			args{tokens.Lexerp(`func add(a, b) 'say hi'`)},
+			Block{[]Node{}},
+			true,
+		},
+		{
+			"func with invalid parameters",
+			args{tokens.Lexerp(`func add(a b) { }`)},
+			Block{[]Node{}},
+			true,
+		},
+		{
+			"while",
+			args{tokens.Lexerp(`
+				while not s[i] >= 10 {
+					s[i]++
+				}
+			`)},
+			Block{
+				[]Node{
+					While{MakeStoreAccess("s", "i", true), tokens.OperationGte, Int{10}, true, Block{[]Node{
+						MakeStoreAssign("s", "i", true, tokens.OperationAdd, Int{1}),
+					}}, none},
+				},
+			},
+			false,
+		},
+		{
+			"while without body",
+			args{tokens.Lexerp(`while s[i] < 10 s[i]++`)},
+			Block{[]Node{}},
+			true,
+		},
+		{
+			"for",
+			args{tokens.Lexerp(`
+				for i in 0..s[n] + 1 {
+					s[x] += i
+				}
+				repeat 3 { 'say hi' }
+			`)},
+			Block{
+				[]Node{
+					For{"i", Int{0}, Calculation{MakeStoreAccess("s", "n", true), tokens.OperationAdd, Int{1}}, Block{[]Node{
+						MakeStoreAssign("s", "x", true, tokens.OperationAdd, Variable{"i"}),
+					}}, none},
+					Repeat{Int{3}, Block{[]Node{String{Value: "say hi"}}}, none},
+				},
+			},
+			false,
+		},
+		{
+			"for without range",
+			args{tokens.Lexerp(`for i in 10 { }`)},
+			Block{[]Node{}},
+			true,
+		},
+		{
+			"unclosed parenthesis",
+			args{tokens.Lexerp(`a[b] = (1+2`)},
+			Block{[]Node{}},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -89,9 +315,171 @@ func TestParse(t *testing.T) {
 				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			got = WithoutPositions(got)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Parse() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestParse_Positions(t *testing.T) {
+	program, err := Parse(tokens.Lexerp(`create store s
+s[x] = 1
+if s[x] == 1 {
+	'say one'
+} else if s[x] == 2 {
+	f()
+}
+func f() {
+	return 1
+}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	body := program.(Block).Body
+	_if := body[2].(If)
+	f := body[3].(Func)
+	tests := []struct {
+		name string
+		node Node
+		want int
+	}{
+		{"create store", body[0], 1},
+		{"assignment", body[1], 2},
+		{"if", _if, 3},
+		{"if body", _if.Body.Body[0], 4},
+		{"else if", _if.Else, 5},
+		{"call", _if.Else.(If).Body.Body[0], 6},
+		{"func", f, 8},
+		{"return", f.Body.Body[0], 9},
+		{"value", MakeStoreAccess("s", "x", true), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PositionOf(tt.node).Line; got != tt.want {
+				t.Errorf("PositionOf() line = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Diagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		want   diag.Code
+		line   int
+		column int
+	}{
+		{"missing else body", "if a[b] == 1 { 'x' }\nelse 'y'", diag.MissingBody, 2, 1},
+		{"unclosed parenthesis", "a[b] = (1+2", diag.ExpectedToken, 1, 12},
+		{"missing value", "a[b] = }", diag.ExpectedValue, 1, 8},
+		{"missing comparator", "while a[b] 1 { }", diag.ExpectedToken, 1, 12},
+		{"bare identifier", "create store a\nfoo", diag.ExpectedToken, 2, 1},
+		{"bare identifiers", "bar baz", diag.ExpectedToken, 1, 1},
+		{"bare value", "if a[b] == 1 { a[b] + 1 }", diag.ExpectedToken, 1, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tokens.Lexerp(tt.code))
+			got, ok := diag.From(err)
+			if !ok || len(got) != 1 {
+				t.Fatalf("Parse() error = %v, want one diagnostic", err)
+			}
+			start := got[0].Span.Start
+			if got[0].Code != tt.want || start.Line != tt.line || start.Column != tt.column {
+				t.Errorf("Parse() error = %v, want %s at %d:%d", got[0], tt.want, tt.line, tt.column)
+			}
+		})
+	}
+}
+
+func TestParse_Recovery(t *testing.T) {
+	program, err := Parse(tokens.Lexerp(`create store s
+s[x] = )
+s[y] = 2
+if s[x] 1 {
+	'say skipped'
+}
+while s[x] < 2 {
+	s[x] += *
+	s[x]++
+}
+as '@a' { 'say a'`))
+	got, ok := diag.From(err)
+	if !ok {
+		t.Fatalf("Parse() error = %v, want diagnostics", err)
+	}
+	lines := make([]int, len(got))
+	for i, diagnostic := range got {
+		lines[i] = diagnostic.Span.Start.Line
+	}
+	if want := []int{2, 4, 8, 11}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("Parse() error lines = %v, want %v\n%v", lines, want, err)
+	}
+	want := Block{[]Node{
+		CreateStore{"s", "", none},
+		MakeStoreAssign("s", "y", true, tokens.OperationSet, Int{2}),
+		While{MakeStoreAccess("s", "x", true), tokens.OperationLt, Int{2}, false, Block{[]Node{
+			MakeStoreAssign("s", "x", true, tokens.OperationAdd, Int{1}),
+		}}, none},
+		As{"@a", Block{[]Node{String{Value: "say a"}}}, none},
+	}}
+	if program = WithoutPositions(program); !reflect.DeepEqual(program, want) {
+		t.Errorf("Parse() = %v, want %v", program, want)
+	}
+}
+
+func TestParse_ConsecutiveErrors(t *testing.T) {
+	_, err := Parse(tokens.Lexerp(`create store s
+s[x] = (1
+s[z] = 2 +* 3
+s[y] = 3 +
+if s[y] == {
+	'say skipped'
+}
+for i 0..2 { }`))
+	got, ok := diag.From(err)
+	if !ok {
+		t.Fatalf("Parse() error = %v, want diagnostics", err)
+	}
+	positions := make([][2]int, len(got))
+	for i, diagnostic := range got {
+		positions[i] = [2]int{diagnostic.Span.Start.Line, diagnostic.Span.Start.Column}
+	}
+	want := [][2]int{{2, 10}, {3, 11}, {4, 11}, {5, 12}, {8, 7}}
+	if !reflect.DeepEqual(positions, want) {
+		t.Errorf("Parse() error positions = %v, want %v\n%v", positions, want, err)
+	}
+}
+
+func TestParse_Docs(t *testing.T) {
+	program, err := Parse(tokens.Lexerp(`/// Counts the kills
+/// of every player
+create store kills
+// not documented
+create store tmp
+/// Adds two numbers
+func add(a, b) {
+	return a + b
+}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	body := program.(Block).Body
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"store", body[0].(CreateStore).Doc, "Counts the kills\nof every player"},
+		{"undocumented store", body[1].(CreateStore).Doc, ""},
+		{"func", body[2].(Func).Doc, "Adds two numbers"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Parse() %s doc = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
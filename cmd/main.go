@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"log"
@@ -11,88 +15,424 @@ import (
 	"strings"
 
 	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/datapack"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/formatter"
+	"github.com/worldOneo/datapacklang/lsp"
 	"github.com/worldOneo/datapacklang/tokens"
 	"github.com/worldOneo/datapacklang/translator"
 )
 
+// Exit codes of the compiler, invalid flags exit with 2 like the flag package.
+const (
+	exitFailure  = 1
+	exitSyntax   = 3
+	exitSemantic = 4
+	exitIO       = 5
+)
+
 func main() {
 	var file string
 	var overwrite bool
+	var pack string
+	var out string
+	var packFormat int
+	var description string
+	var load string
+	var tick string
+	var manifest string
+	var sourceMap string
+	var reference string
+	var format string
+	options := translator.DefaultOptions()
 	flag.StringVar(&file, "file", "main.dpl", "Defines the file to translate to mcfunction")
 	flag.BoolVar(&overwrite, "overwrite", false, "If overwrite is defined already existing .mcfunction files will be overwritten by the compilation of a .dpl file")
+	flag.StringVar(&options.Namespace, "namespace", options.Namespace, "Namespace of the datapack the generated functions are called from")
+	flag.BoolVar(&options.BlockFunctions, "blocks", options.BlockFunctions, "If blocks is defined bodies of as, if and scoped blocks are compiled into own functions")
+	flag.IntVar(&options.InlineThreshold, "inline", options.InlineThreshold, "Maximum amount of commands of a body which is still inlined if -blocks is defined")
+	flag.IntVar(&options.MaxIterations, "max-iterations", options.MaxIterations, "Loops are stopped after this many iterations, 0 disables the guard, -1 derives the limit from the size of each loop")
+	flag.StringVar(&options.Prefix, "prefix", options.Prefix, "Prefix of the generated objectives and fake players, so they don't collide with other datapacks")
+	flag.BoolVar(&options.DebugNames, "debug-names", options.DebugNames, "If debug-names is defined stores and variables keep their names, they are only shortened on conflicts")
+	flag.BoolVar(&options.Comments, "comments", options.Comments, "If comments is defined the comments of the sources are written as # lines into the functions")
+	flag.IntVar(&options.UnrollLimit, "unroll", options.UnrollLimit, "For and repeat loops with literal bounds and at most this many iterations are unrolled")
+	flag.StringVar(&pack, "pack", "", "If pack is defined a complete datapack is written into this directory instead of .mcfunction files next to the sources")
+	flag.StringVar(&out, "out", "", "If out is defined a complete datapack is written into this zip file")
+	flag.IntVar(&packFormat, "pack-format", datapack.DefaultFormat, "pack_format written to the pack.mcmeta")
+	flag.StringVar(&description, "description", "", "Description written to the pack.mcmeta")
+	flag.StringVar(&load, "load", "load", "Function added to the #minecraft:load tag if it is compiled")
+	flag.StringVar(&tick, "tick", "tick", "Function added to the #minecraft:tick tag if it is compiled")
+	flag.StringVar(&manifest, "manifest", "", "If manifest is defined the generated names of stores and variables are written into this json file")
+	flag.StringVar(&sourceMap, "sourcemap", "", "If sourcemap is defined the line every generated command originates from is written into this json file")
+	flag.StringVar(&reference, "reference", "", "If reference is defined the stores and funcs with their /// comments are written into this markdown file")
+	flag.StringVar(&format, "format", "text", "Format of the reported errors, text or json to print one json object per line to stdout")
 
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		formatCommand(os.Args[2:])
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		flag.CommandLine.Parse(os.Args[2:])
+		serve(options)
+	}
 	flag.Parse()
 
-	info, err := os.Stat(file)
+	if format != "text" && format != "json" {
+		log.Fatalf("Unknown format %s", format)
+	}
+	// fail reports the error in the format and exits
+	fail := func(err error, code int) {
+		os.Exit(report(os.Stdout, os.Stderr, format, err, code))
+	}
 
+	if pack != "" && out != "" {
+		fail(fmt.Errorf("Only one of -pack and -out can be defined"), exitFailure)
+	}
+	err := options.Validate()
 	if err != nil {
-		log.Fatal(err)
+		fail(err, exitFailure)
 	}
 
-	if !info.IsDir() {
-		err := TranslateFile(file, overwrite)
+	build, err := Compile(file, options)
+	if err != nil {
+		fail(err, exitIO)
+	}
+
+	if manifest != "" {
+		err = WriteJSON(manifest, build.Manifest, overwrite)
 		if err != nil {
-			log.Fatal(err)
+			fail(err, exitIO)
 		}
+	}
+	if sourceMap != "" {
+		err = WriteJSON(sourceMap, build.SourceMap, overwrite)
+		if err != nil {
+			fail(err, exitIO)
+		}
+	}
+
+	if reference != "" {
+		err = datapack.WriteFile(reference, []byte(translator.Reference(build.Declarations)), overwrite)
+		if err != nil {
+			fail(err, exitIO)
+		}
+	}
+
+	if pack == "" && out == "" {
+		err = WriteFunctions(build.Root, build.Functions, overwrite)
+		if err != nil {
+			fail(err, exitIO)
+		}
+		os.Exit(0)
+	}
+
+	datapack, err := BuildPack(build.Functions, build.Loads, options.Namespace, packFormat, description, load, tick)
+	if err != nil {
+		fail(err, exitFailure)
+	}
+	if pack != "" {
+		err = datapack.WriteDir(pack, overwrite)
 	} else {
+		err = WriteZip(out, datapack, overwrite)
+	}
+	if err != nil {
+		fail(err, exitIO)
+	}
+	os.Exit(0)
+}
+
+// serve runs the language server on stdin and stdout and exits once the client exits.
+func serve(options translator.Options) {
+	err := options.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = lsp.NewServer(os.Stdin, os.Stdout, options).Serve()
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(0)
+}
+
+// formatCommand formats the .dpl files of the arguments in place and exits.
+// With -check the files aren't written, unformatted files are listed and the exit code is 1 if there are any.
+func formatCommand(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "If check is defined files aren't written, unformatted files are listed and fail the command")
+	flags.Parse(args)
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"."}
+	}
+
+	unformatted := make([]string, 0)
+	diagnostics := make(diag.List, 0)
+	for _, file := range files {
 		err := filepath.Walk(file, func(path string, info fs.FileInfo, err error) error {
 			if err != nil {
-				return filepath.SkipDir
+				return err
 			}
-			if info.IsDir() {
+			if info.IsDir() || filepath.Ext(path) != ".dpl" {
+				return nil
+			}
+			changed, err := FormatFile(path, *check)
+			if list, ok := diag.From(err); ok {
+				diagnostics = append(diagnostics, list.InFile(path)...)
 				return nil
 			}
-			return TranslateFile(path, overwrite)
+			if changed {
+				unformatted = append(unformatted, path)
+			}
+			return err
 		})
 		if err != nil {
-			log.Fatal(err)
+			os.Exit(report(os.Stdout, os.Stderr, "text", err, failure(err)))
+		}
+	}
+	if len(diagnostics) > 0 {
+		os.Exit(report(os.Stdout, os.Stderr, "text", diagnostics, exitSyntax))
+	}
+	if *check {
+		for _, file := range unformatted {
+			fmt.Println(file)
+		}
+		if len(unformatted) > 0 {
+			os.Exit(exitFailure)
 		}
 	}
 	os.Exit(0)
 }
 
-func TranslateFile(path string, overwrite bool) error {
-	if filepath.Ext(path) != ".dpl" {
-		return nil
-	}
-	newFile := strings.TrimSuffix(path, filepath.Ext(path)) + ".mcfunction"
-	if !overwrite {
-		info, err := os.Stat(newFile)
-		if err == nil {
-			return fmt.Errorf("File %s already exists use -overwrite to overwrite the old file", newFile)
+// report writes the error in the format and returns the exit code.
+// Diagnostics exit with exitSyntax or exitSemantic, other errors with the code and failing to write them with exitIO.
+// With json every error is written to stdout, otherwise diagnostics are rendered and other errors logged to stderr.
+func report(stdout, stderr io.Writer, format string, err error, code int) int {
+	diagnostics, ok := diag.From(err)
+	if ok {
+		code = exitSemantic
+		if diagnostics.Syntax() {
+			code = exitSyntax
 		}
-		if !os.IsNotExist(err) {
-			return err
+	} else {
+		diagnostics = diag.List{{Severity: diag.Error, Message: err.Error()}}
+	}
+
+	logger := log.New(stderr, "", log.LstdFlags)
+	var written error
+	if format == "json" {
+		written = diag.WriteJSON(stdout, diagnostics)
+	} else if ok {
+		written = Report(stderr, diagnostics)
+	} else {
+		logger.Print(err)
+	}
+	if written != nil {
+		logger.Print(written)
+		return exitIO
+	}
+	return code
+}
+
+// failure returns the exit code of an error which isn't a diagnostic, exitIO if reading or writing a file failed.
+func failure(err error) int {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return exitIO
+	}
+	return exitFailure
+}
+
+// FormatFile formats the .dpl file and reports whether it changed, with check the file isn't written.
+func FormatFile(path string, check bool) (bool, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	formatted, err := formatter.Format(string(content))
+	if err != nil {
+		return false, err
+	}
+	if formatted == string(content) {
+		return false, nil
+	}
+	if check {
+		return true, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return true, ioutil.WriteFile(path, []byte(formatted), info.Mode())
+}
+
+// Build is the result of compiling the sources.
+type Build struct {
+	// Root is the directory function names are relative to
+	Root      string
+	Functions []translator.Function
+	// Loads are the names of the functions creating the objectives which have to run on load
+	Loads        []string
+	Manifest     translator.Manifest
+	SourceMap    translator.SourceMap
+	Declarations []translator.Declaration
+}
+
+// Compile translates the .dpl file or every .dpl file in the directory as one unit.
+// The uninstall function removing the objectives is added to the functions.
+// Files are compiled even after one failed, the diagnostics of all files are returned as diag.List.
+func Compile(file string, options translator.Options) (Build, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return Build{}, err
+	}
+
+	unit := translator.NewUnit(options)
+	functions := make([]translator.Function, 0)
+	diagnostics := make(diag.List, 0)
+	add := func(root, path string) error {
+		translated, err := TranslateFile(root, path, unit)
+		if list, ok := diag.From(err); ok {
+			diagnostics = append(diagnostics, list.InFile(path)...)
+			return nil
 		}
-		if info != nil {
+		functions = append(functions, translated...)
+		return err
+	}
+
+	root := file
+	if !info.IsDir() {
+		root = filepath.Dir(file)
+		err = add(root, file)
+	} else {
+		err = filepath.Walk(file, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return filepath.SkipDir
+			}
 			if info.IsDir() {
-				return fmt.Errorf("Path %s is directory but file required", path)
+				return nil
 			}
-		}
+			return add(file, path)
+		})
+	}
+	if err != nil {
+		return Build{}, err
+	}
+	if len(diagnostics) > 0 {
+		return Build{}, diagnostics
+	}
+
+	functions = append(functions, unit.Functions()...)
+	loads := make([]string, 0)
+	if load := unit.Load(); len(load.Commands) > 0 {
+		functions = append(functions, load)
+		loads = append(loads, load.Name)
+	}
+	if uninstall := unit.Uninstall(); len(uninstall.Commands) > 0 {
+		functions = append(functions, uninstall)
+	}
+	return Build{root, functions, loads, unit.Manifest(), unit.SourceMap(), unit.Declarations()}, nil
+}
+
+// TranslateFile translates the .dpl file at path into the function of the file.
+// Root is the functions directory of the namespace, the function of the file is named by its path relative to it.
+// Functions generated for the file are collected by the unit.
+func TranslateFile(root, path string, unit *translator.Unit) ([]translator.Function, error) {
+	if filepath.Ext(path) != ".dpl" {
+		return nil, nil
 	}
+	module, err := filepath.Rel(root, strings.TrimSuffix(path, filepath.Ext(path)))
+	if err != nil {
+		return nil, err
+	}
+	module = filepath.ToSlash(module)
+
 	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	code := string(content)
-	tokens, err := tokens.Lexer(code)
+	tokens, comments, lexErr := tokens.LexerComments(code)
+	parsed, err := ast.Parse(tokens)
+	err = diag.Join(lexErr, err)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	parsed, err := ast.Parse(tokens)
+	res, err := unit.Translate(module, parsed, comments)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	translator := translator.New()
-	res, err := translator.Translate(parsed)
+	return []translator.Function{{Name: module, Commands: res}}, nil
+}
+
+// Report renders the diagnostics with the line of the file they were reported at.
+func Report(w io.Writer, diagnostics diag.List) error {
+	sources := make(map[string]string)
+	for _, diagnostic := range diagnostics {
+		source, ok := sources[diagnostic.File]
+		if !ok {
+			content, err := ioutil.ReadFile(diagnostic.File)
+			if err != nil {
+				return err
+			}
+			source = string(content)
+			sources[diagnostic.File] = source
+		}
+		err := diag.Render(w, diagnostic, source)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFunctions writes the functions as .mcfunction files into the root directory,
+// which places the function of a .dpl file next to it.
+func WriteFunctions(root string, functions []translator.Function, overwrite bool) error {
+	for _, function := range functions {
+		file := filepath.Join(root, filepath.FromSlash(function.Name)) + ".mcfunction"
+		err := datapack.WriteFile(file, []byte(strings.Join(function.Commands, "\r\n")), overwrite)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildPack creates a loadable datapack with the functions.
+// The loads are tagged before the load function of the program so its objectives exist when it runs.
+func BuildPack(functions []translator.Function, loads []string, namespace string, format int, description, load, tick string) (*datapack.Pack, error) {
+	pack := datapack.New(format, description)
+	for _, name := range loads {
+		pack.Tag(datapack.TagLoad, namespace+":"+name)
+	}
+	for _, function := range functions {
+		err := pack.AddFunction(namespace, function.Name, function.Commands)
+		if err != nil {
+			return nil, err
+		}
+		switch function.Name {
+		case load:
+			pack.Tag(datapack.TagLoad, namespace+":"+function.Name)
+		case tick:
+			pack.Tag(datapack.TagTick, namespace+":"+function.Name)
+		}
+	}
+	return pack, nil
+}
+
+// WriteJSON writes the value as json file.
+func WriteJSON(file string, value interface{}, overwrite bool) error {
+	content, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
 		return err
 	}
+	return datapack.WriteFile(file, content, overwrite)
+}
 
-	file, err := os.OpenFile(newFile, os.O_TRUNC|os.O_CREATE, 0o660)
+// WriteZip writes the pack into the zip file.
+func WriteZip(file string, pack *datapack.Pack, overwrite bool) error {
+	buffer := bytes.Buffer{}
+	err := pack.WriteZip(&buffer)
 	if err != nil {
 		return err
 	}
-	_, err = file.Write([]byte(strings.Join(res, "\r\n")))
-	return err
+	return datapack.WriteFile(file, buffer.Bytes(), overwrite)
 }
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/worldOneo/datapacklang/diag"
+)
+
+func TestReport(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.dpl")
+	err := ioutil.WriteFile(file, []byte("s[x] = f()\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := diag.Span{Start: diag.Position{Line: 1, Column: 8}, End: diag.Position{Line: 1, Column: 9}}
+	syntax := diag.Diagnostic{Severity: diag.Error, Code: diag.ExpectedValue, File: file, Span: span, Message: "Expected value"}
+	semantic := diag.Diagnostic{Severity: diag.Error, Code: diag.UnknownFunc, File: file, Span: span, Message: "Unknown func f"}
+	missing := filepath.Join(t.TempDir(), "missing.dpl")
+	_, notExist := os.Stat(missing)
+
+	tests := []struct {
+		name       string
+		format     string
+		err        error
+		code       int
+		want       int
+		wantStdout string
+		wantStderr string
+	}{
+		{"syntax", "text", diag.List{semantic, syntax}, exitIO, exitSyntax, "", syntax.Error() + "\n 1 | s[x] = f()\n   |        ^\n"},
+		{"semantic", "text", diag.List{semantic}, exitIO, exitSemantic, "", semantic.Error()},
+		{"wrapped", "text", fmt.Errorf("compiling: %w", diag.List{semantic}), exitIO, exitSemantic, "", semantic.Error()},
+		{"io", "text", notExist, exitIO, exitIO, "", notExist.Error()},
+		{"failure", "text", fmt.Errorf("Only one of -pack and -out can be defined"), exitFailure, exitFailure, "", "Only one of -pack and -out can be defined"},
+		{"unreadable source", "text", diag.List{{Severity: diag.Error, Code: diag.UnknownFunc, File: missing, Span: span, Message: "Unknown func f"}}, exitIO, exitIO, "", "missing.dpl"},
+		{"json syntax", "json", diag.List{syntax}, exitIO, exitSyntax,
+			fmt.Sprintf(`{"file":%q,"line":1,"column":8,"endLine":1,"endColumn":9,"severity":"error","code":"E103","message":"Expected value"}`+"\n", file), ""},
+		{"json semantic", "json", diag.List{semantic}, exitIO, exitSemantic,
+			fmt.Sprintf(`{"file":%q,"line":1,"column":8,"endLine":1,"endColumn":9,"severity":"error","code":"E201","message":"Unknown func f"}`+"\n", file), ""},
+		{"json failure", "json", fmt.Errorf("Invalid namespace"), exitFailure, exitFailure,
+			`{"file":"","line":0,"column":0,"endLine":0,"endColumn":0,"severity":"error","message":"Invalid namespace"}` + "\n", ""},
+		{"json io", "json", notExist, exitIO, exitIO,
+			fmt.Sprintf(`{"file":"","line":0,"column":0,"endLine":0,"endColumn":0,"severity":"error","message":%q}`+"\n", notExist.Error()), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+			if got := report(&stdout, &stderr, tt.format, tt.err, tt.code); got != tt.want {
+				t.Errorf("report() = %d, want %d", got, tt.want)
+			}
+			if stdout.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) || (tt.wantStderr == "") != (stderr.Len() == 0) {
+				t.Errorf("stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestFailure(t *testing.T) {
+	_, notExist := ioutil.ReadFile(filepath.Join(t.TempDir(), "missing.dpl"))
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"io", notExist, exitIO},
+		{"wrapped io", fmt.Errorf("formatting: %w", notExist), exitIO},
+		{"failure", fmt.Errorf("Formatting changed the program"), exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := failure(tt.err); got != tt.want {
+				t.Errorf("failure() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
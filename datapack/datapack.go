@@ -0,0 +1,174 @@
+package datapack
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultFormat is the pack_format of minecraft 1.17.
+	DefaultFormat = 7
+
+	metaFile     = "pack.mcmeta"
+	functionFile = "data/%s/functions/%s.mcfunction"
+	tagFile      = "data/minecraft/tags/functions/%s.json"
+	lineEnding   = "\r\n"
+)
+
+const (
+	TagLoad = "load"
+	TagTick = "tick"
+)
+
+// Pack collects the files of a datapack before they are written.
+type Pack struct {
+	Format      int
+	Description string
+	files       map[string][]byte
+	tags        map[string][]string
+}
+
+type meta struct {
+	Pack struct {
+		Format      int    `json:"pack_format"`
+		Description string `json:"description"`
+	} `json:"pack"`
+}
+
+type tag struct {
+	Values []string `json:"values"`
+}
+
+func New(format int, description string) *Pack {
+	return &Pack{
+		format,
+		description,
+		make(map[string][]byte),
+		make(map[string][]string),
+	}
+}
+
+// AddFunction adds the function at data/<namespace>/functions/<name>.mcfunction.
+func (P *Pack) AddFunction(namespace, name string, commands []string) error {
+	file := fmt.Sprintf(functionFile, namespace, name)
+	if _, ok := P.files[file]; ok {
+		return fmt.Errorf("Function %s:%s is defined twice", namespace, name)
+	}
+	P.files[file] = []byte(strings.Join(commands, lineEnding))
+	return nil
+}
+
+// Tag adds the function to a function tag of the minecraft namespace like load or tick.
+func (P *Pack) Tag(name, function string) {
+	P.tags[name] = append(P.tags[name], function)
+}
+
+// Files returns the content of every file of the pack by its path.
+// Paths are relative to the root of the pack and use / as separator.
+func (P *Pack) Files() (map[string][]byte, error) {
+	files := make(map[string][]byte, len(P.files)+len(P.tags)+1)
+	for file, content := range P.files {
+		files[file] = content
+	}
+
+	mcmeta := meta{}
+	mcmeta.Pack.Format = P.Format
+	mcmeta.Pack.Description = P.Description
+	content, err := json.MarshalIndent(mcmeta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[metaFile] = content
+
+	for name, functions := range P.tags {
+		content, err := json.MarshalIndent(tag{functions}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files[fmt.Sprintf(tagFile, name)] = content
+	}
+	return files, nil
+}
+
+// Paths returns the sorted paths of the files.
+func Paths(files map[string][]byte) []string {
+	paths := make([]string, 0, len(files))
+	for file := range files {
+		paths = append(paths, file)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// WriteDir writes the pack into the directory.
+func (P *Pack) WriteDir(dir string, overwrite bool) error {
+	files, err := P.Files()
+	if err != nil {
+		return err
+	}
+	for _, file := range Paths(files) {
+		err := WriteFile(filepath.Join(dir, filepath.FromSlash(path.Clean(file))), files[file], overwrite)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipTime is the modification time of every zipped file, so equal packs produce equal archives.
+var zipTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// WriteZip writes the pack as zip archive.
+// Files are ordered by their path and have a fixed modification time to make builds reproducible.
+func (P *Pack) WriteZip(w io.Writer) error {
+	files, err := P.Files()
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(w)
+	for _, file := range Paths(files) {
+		writer, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     file,
+			Method:   zip.Deflate,
+			Modified: zipTime,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(files[file])
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// WriteFile writes the content into the file creating missing directories.
+// Existing files are only replaced if overwrite is set.
+func WriteFile(file string, content []byte, overwrite bool) error {
+	info, err := os.Stat(file)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("Path %s is directory but file required", file)
+		}
+		if !overwrite {
+			return fmt.Errorf("File %s already exists use -overwrite to overwrite the old file", file)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0o775)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, 0o660)
+}
@@ -0,0 +1,131 @@
+package datapack
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestPack_Files(t *testing.T) {
+	tests := []struct {
+		name      string
+		functions map[string][]string
+		tags      map[string][]string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{
+			"functions and tags",
+			map[string][]string{
+				"load":       {"say a", "say b"},
+				"sub/tick":   {"say tick"},
+				"__dpl/loop": {},
+			},
+			map[string][]string{
+				TagLoad: {"pack:load"},
+				TagTick: {"pack:sub/tick"},
+			},
+			map[string]string{
+				"pack.mcmeta":                               "{\n  \"pack\": {\n    \"pack_format\": 7,\n    \"description\": \"test\"\n  }\n}",
+				"data/pack/functions/load.mcfunction":       "say a\r\nsay b",
+				"data/pack/functions/sub/tick.mcfunction":   "say tick",
+				"data/pack/functions/__dpl/loop.mcfunction": "",
+				"data/minecraft/tags/functions/load.json":   "{\n  \"values\": [\n    \"pack:load\"\n  ]\n}",
+				"data/minecraft/tags/functions/tick.json":   "{\n  \"values\": [\n    \"pack:sub/tick\"\n  ]\n}",
+			},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			P := New(DefaultFormat, "test")
+			for name, commands := range tt.functions {
+				err := P.AddFunction("pack", name, commands)
+				if err != nil {
+					t.Fatalf("AddFunction() error = %v", err)
+				}
+			}
+			for name, functions := range tt.tags {
+				for _, function := range functions {
+					P.Tag(name, function)
+				}
+			}
+			files, err := P.Files()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Files() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			got := make(map[string]string)
+			for file, content := range files {
+				got[file] = string(content)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Files() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPack_AddFunction(t *testing.T) {
+	P := New(DefaultFormat, "")
+	if err := P.AddFunction("pack", "main", []string{"say a"}); err != nil {
+		t.Fatalf("AddFunction() error = %v", err)
+	}
+	if err := P.AddFunction("pack", "main", []string{"say b"}); err == nil {
+		t.Errorf("AddFunction() of a duplicate function succeeded")
+	}
+}
+
+func TestPack_WriteZip(t *testing.T) {
+	build := func(names ...string) []byte {
+		P := New(DefaultFormat, "zip")
+		for _, name := range names {
+			if err := P.AddFunction("pack", name, []string{"say " + name}); err != nil {
+				t.Fatalf("AddFunction() error = %v", err)
+			}
+		}
+		P.Tag(TagLoad, "pack:a")
+		buffer := bytes.Buffer{}
+		if err := P.WriteZip(&buffer); err != nil {
+			t.Fatalf("WriteZip() error = %v", err)
+		}
+		return buffer.Bytes()
+	}
+	first := build("a", "b/c", "d")
+	second := build("d", "a", "b/c")
+	if !bytes.Equal(first, second) {
+		t.Errorf("WriteZip() isn't reproducible")
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	names := make([]string, 0)
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+		if !file.Modified.Equal(zipTime) {
+			t.Errorf("%s modified at %v, want %v", file.Name, file.Modified, zipTime)
+		}
+	}
+	want := []string{
+		"data/minecraft/tags/functions/load.json",
+		"data/pack/functions/a.mcfunction",
+		"data/pack/functions/b/c.mcfunction",
+		"data/pack/functions/d.mcfunction",
+		"pack.mcmeta",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("WriteZip() files = %v, want %v", names, want)
+	}
+	reader, err := archive.File[2].Open()
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil || string(content) != "say b/c" {
+		t.Errorf("content = %q, %v, want %q", content, err, "say b/c")
+	}
+}
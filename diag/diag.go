@@ -0,0 +1,197 @@
+// Package diag describes problems found in the source code of a program.
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Position is a location in the source code.
+type Position struct {
+	// Line starting at 1, 0 if the position is unknown
+	Line int
+	// Column in characters starting at 1
+	Column int
+	// Offset in bytes from the start of the source code
+	Offset int
+}
+
+// Span is the range of source code from Start up to, but excluding, End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Point is the span of a single character at the position.
+func Point(position Position) Span {
+	end := position
+	end.Column++
+	end.Offset++
+	return Span{position, end}
+}
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (S Severity) String() string {
+	if S == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Code identifies the kind of a diagnostic.
+// Codes below 200 are syntax errors, every other code is a semantic error.
+type Code int
+
+const (
+	UnterminatedString Code = 101 + iota
+	InvalidNumber
+	ExpectedValue
+	ExpectedToken
+	MissingBody
+	UnterminatedComment
+	NestedComment
+)
+
+const (
+	UnknownFunc Code = 201 + iota
+	UnknownVariable
+	InvalidDeclaration
+	ArgumentCount
+	MissingReturn
+	InvalidOperation
+)
+
+func (C Code) String() string {
+	return fmt.Sprintf("E%d", int(C))
+}
+
+// Syntax reports whether the code is a syntax error of the lexer or parser.
+func (C Code) Syntax() bool {
+	return C < 200
+}
+
+// Diagnostic is a problem at a span of a file.
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	// File is the path of the file the diagnostic was reported for, empty if unknown
+	File    string
+	Span    Span
+	Message string
+}
+
+// Errorf creates an error diagnostic at the span.
+func Errorf(code Code, span Span, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{Error, code, "", span, fmt.Sprintf(format, args...)}
+}
+
+// Error formats the diagnostic as file:line:column: severity code: message.
+func (D Diagnostic) Error() string {
+	location := D.File
+	if D.Span.Start.Line > 0 {
+		location = fmt.Sprintf("%s:%d:%d", location, D.Span.Start.Line, D.Span.Start.Column)
+	}
+	location = strings.TrimPrefix(location, ":")
+	if location != "" {
+		location += ": "
+	}
+	return fmt.Sprintf("%s%s %s: %s", location, D.Severity, D.Code, D.Message)
+}
+
+// List is a collection of diagnostics which is an error itself.
+type List []Diagnostic
+
+func (L List) Error() string {
+	messages := make([]string, len(L))
+	for i, diagnostic := range L {
+		messages[i] = diagnostic.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Err returns the list as error if it contains an error and nil otherwise.
+func (L List) Err() error {
+	for _, diagnostic := range L {
+		if diagnostic.Severity == Error {
+			return L
+		}
+	}
+	return nil
+}
+
+// Syntax reports whether the list contains a syntax error.
+func (L List) Syntax() bool {
+	for _, diagnostic := range L {
+		if diagnostic.Severity == Error && diagnostic.Code.Syntax() {
+			return true
+		}
+	}
+	return false
+}
+
+// InFile sets the file of every diagnostic.
+func (L List) InFile(file string) List {
+	for i := range L {
+		L[i].File = file
+	}
+	return L
+}
+
+// At sets the span of every diagnostic of the error which has no position yet.
+func At(err error, span Span) error {
+	list, ok := From(err)
+	if !ok {
+		return err
+	}
+	for i := range list {
+		if list[i].Span.Start.Line == 0 {
+			list[i].Span = span
+		}
+	}
+	return list
+}
+
+// From returns the diagnostics of a Diagnostic or List error.
+func From(err error) (List, bool) {
+	var list List
+	if errors.As(err, &list) {
+		return list, true
+	}
+	var diagnostic Diagnostic
+	if errors.As(err, &diagnostic) {
+		return List{diagnostic}, true
+	}
+	return nil, false
+}
+
+// Join combines the diagnostics of the errors ordered by their position, diagnostics without position come last.
+// The first error which isn't a diagnostic is returned as it is.
+func Join(errs ...error) error {
+	joined := make(List, 0)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		list, ok := From(err)
+		if !ok {
+			return err
+		}
+		joined = append(joined, list...)
+	}
+	sort.SliceStable(joined, func(i, j int) bool {
+		a, b := joined[i].Span.Start, joined[j].Span.Start
+		if a.Line == 0 || b.Line == 0 {
+			return b.Line == 0 && a.Line != 0
+		}
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return joined.Err()
+}
@@ -0,0 +1,140 @@
+package diag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func span(line, column, endColumn int) Span {
+	return Span{Position{Line: line, Column: column}, Position{Line: line, Column: endColumn}}
+}
+
+func TestDiagnostic_Error(t *testing.T) {
+	tests := []struct {
+		name       string
+		diagnostic Diagnostic
+		want       string
+	}{
+		{"file and span", Diagnostic{Error, UnknownFunc, "main.dpl", span(2, 8, 9), "Unknown func f"}, "main.dpl:2:8: error E201: Unknown func f"},
+		{"span", Diagnostic{Error, ExpectedValue, "", span(1, 3, 4), "Expected value"}, "1:3: error E103: Expected value"},
+		{"file", Diagnostic{Warning, InvalidOperation, "main.dpl", Span{}, "Invalid"}, "main.dpl: warning E206: Invalid"},
+		{"nothing", Diagnostic{Error, InvalidOperation, "", Span{}, "Invalid"}, "error E206: Invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.diagnostic.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	list := List{Errorf(UnknownFunc, Span{}, "Unknown func f"), Errorf(UnknownVariable, span(3, 1, 2), "Unknown variable x")}
+	err := fmt.Errorf("translating: %w", At(list, span(1, 1, 2)))
+	got, ok := From(err)
+	if !ok || len(got) != 2 {
+		t.Fatalf("From() = %v, %v, want both diagnostics", got, ok)
+	}
+	if got[0].Span.Start.Line != 1 || got[1].Span.Start.Line != 3 {
+		t.Errorf("At() = %v, want only the diagnostic without position moved to line 1", got)
+	}
+	if _, ok := From(errors.New("io")); ok {
+		t.Errorf("From() of a plain error ok = true, want false")
+	}
+	if err := (List{{Warning, InvalidOperation, "", Span{}, "Invalid"}}).Err(); err != nil {
+		t.Errorf("Err() of warnings = %v, want nil", err)
+	}
+	if !UnterminatedString.Syntax() || UnknownFunc.Syntax() {
+		t.Errorf("Syntax() doesn't separate syntax and semantic errors")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	lexed := List{Errorf(UnterminatedString, span(3, 8, 9), "Unterminated string"), Errorf(InvalidNumber, span(1, 5, 9), "Invalid int literal")}
+	parsed := List{Errorf(ExpectedValue, span(2, 7, 8), "Expected value"), Errorf(UnknownFunc, Span{}, "Unknown func f")}
+	got, ok := From(Join(lexed, nil, parsed))
+	if !ok || len(got) != 4 {
+		t.Fatalf("Join() = %v, want all diagnostics", got)
+	}
+	want := []Code{UnknownFunc, InvalidNumber, ExpectedValue, UnterminatedString}
+	for i, code := range []Code{got[3].Code, got[0].Code, got[1].Code, got[2].Code} {
+		if code != want[i] {
+			t.Errorf("Join() = %v, want ordered by position", got)
+		}
+	}
+	if err := errors.New("io"); Join(lexed, err) != err {
+		t.Errorf("Join() of a plain error isn't the plain error")
+	}
+	if err := Join(nil, nil); err != nil {
+		t.Errorf("Join() of no errors = %v, want nil", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		span   Span
+		source string
+		want   string
+	}{
+		{
+			"span",
+			span(2, 8, 11),
+			"create store s\r\ns[x] = f()\r\n",
+			"main.dpl:2:8: error E201: Unknown func f\n 2 | s[x] = f()\n   |        ^^^\n",
+		},
+		{
+			"tabs",
+			span(1, 3, 4),
+			"\t\tf()",
+			"main.dpl:1:3: error E201: Unknown func f\n 1 | \t\tf()\n   | \t\t^\n",
+		},
+		{
+			"multiple lines",
+			Span{Position{Line: 1, Column: 5}, Position{Line: 2, Column: 1}},
+			"s = 'abc\n'",
+			"main.dpl:1:5: error E201: Unknown func f\n 1 | s = 'abc\n   |     ^^^^\n",
+		},
+		{
+			"outside source",
+			span(5, 1, 2),
+			"f()",
+			"main.dpl:5:1: error E201: Unknown func f\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := bytes.Buffer{}
+			diagnostic := Diagnostic{Error, UnknownFunc, "main.dpl", tt.span, "Unknown func f"}
+			if err := Render(&w, diagnostic, tt.source); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	list := List{
+		Diagnostic{Error, ExpectedToken, "main.dpl", span(1, 12, 13), "Closing parenthesis expected"},
+		Diagnostic{Severity: Error, Message: "open main.dpl: no such file or directory"},
+	}
+	w := bytes.Buffer{}
+	if err := WriteJSON(&w, list); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	want := `{"file":"main.dpl","line":1,"column":12,"endLine":1,"endColumn":13,"severity":"error","code":"E104","message":"Closing parenthesis expected"}
+{"file":"","line":0,"column":0,"endLine":0,"endColumn":0,"severity":"error","message":"open main.dpl: no such file or directory"}
+`
+	if got := w.String(); got != want {
+		t.Errorf("WriteJSON() = %s, want %s", got, want)
+	}
+	if !list.Syntax() || (List{Errorf(UnknownFunc, Span{}, "Unknown func f")}).Syntax() {
+		t.Errorf("Syntax() doesn't match the codes of the list")
+	}
+}
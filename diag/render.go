@@ -0,0 +1,98 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Render writes the diagnostic followed by the line of the source code it was reported at
+// and carets below the span:
+//
+//	main.dpl:2:8: error E201: Unknown func f
+//	  2 | s[x] = f()
+//	    |        ^
+func Render(w io.Writer, diagnostic Diagnostic, source string) error {
+	_, err := fmt.Fprintln(w, diagnostic.Error())
+	if err != nil {
+		return err
+	}
+	start, end := diagnostic.Span.Start, diagnostic.Span.End
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+	if start.Line < 1 || start.Line > len(lines) {
+		return nil
+	}
+	line := strings.TrimRight(lines[start.Line-1], "\r")
+	number := fmt.Sprint(start.Line)
+	gutter := strings.Repeat(" ", len(number))
+
+	// Tabs are kept so the carets line up with the source in every tab width
+	indent := strings.Builder{}
+	column := 1
+	for _, c := range line {
+		if column >= start.Column {
+			break
+		}
+		if c == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+		column++
+	}
+	width := 1
+	if end.Line == start.Line && end.Column > start.Column {
+		width = end.Column - start.Column
+	} else if end.Line > start.Line {
+		width = utf8.RuneCountInString(line) - start.Column + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+	_, err = fmt.Fprintf(w, " %s | %s\n %s | %s%s\n", number, line, gutter, indent.String(), strings.Repeat("^", width))
+	return err
+}
+
+// jsonDiagnostic is a diagnostic as written by WriteJSON.
+type jsonDiagnostic struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+}
+
+// WriteJSON writes every diagnostic as json object in its own line:
+//
+//	{"file":"main.dpl","line":2,"column":8,"endLine":2,"endColumn":9,"severity":"error","code":"E201","message":"Unknown func f"}
+//
+// Lines and columns are 0 if the position is unknown, the code is omitted if the diagnostic has none.
+func WriteJSON(w io.Writer, diagnostics List) error {
+	encoder := json.NewEncoder(w)
+	for _, diagnostic := range diagnostics {
+		code := ""
+		if diagnostic.Code != 0 {
+			code = diagnostic.Code.String()
+		}
+		start, end := diagnostic.Span.Start, diagnostic.Span.End
+		err := encoder.Encode(jsonDiagnostic{
+			diagnostic.File,
+			start.Line,
+			start.Column,
+			end.Line,
+			end.Column,
+			diagnostic.Severity.String(),
+			code,
+			diagnostic.Message,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Package formatter prints .dpl sources in a canonical layout.
+package formatter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+const indentation = "  "
+
+// Format returns the code with one statement per line, indented blocks, braces on the line of their statement
+// and single spaces around operators. Comments and literals are kept as written,
+// consecutive blank lines are reduced to one.
+// Code with syntax errors isn't formatted, the returned error is a diag.List.
+func Format(code string) (string, error) {
+	lexed, comments, lexErr := tokens.LexerComments(code)
+	program, err := ast.Parse(lexed)
+	err = diag.Join(lexErr, err)
+	if err != nil {
+		return "", err
+	}
+	newline := "\n"
+	if strings.Contains(code, "\r\n") {
+		newline = "\r\n"
+	}
+	P := printer{
+		code:     code,
+		tokens:   lexed,
+		comments: comments,
+		starts:   make(map[int]bool),
+		newline:  newline,
+	}
+	P.statements(program.(ast.Block))
+	formatted := P.print()
+	err = verify(lexed, comments, program, formatted)
+	if err != nil {
+		return "", err
+	}
+	return formatted, nil
+}
+
+// verify checks that the formatted code consists of the same tokens and comments as the original
+// and that it is parsed into the same program, so changed line breaks can't split or join statements.
+func verify(lexed []tokens.Token, comments []tokens.Comment, program ast.Node, formatted string) error {
+	relexed, recomments, err := tokens.LexerComments(formatted)
+	if err != nil {
+		return err
+	}
+	if len(relexed) != len(lexed) || len(recomments) != len(comments) {
+		return fmt.Errorf("Formatting changed the program")
+	}
+	for i, token := range lexed {
+		if token.Type != relexed[i].Type || token.Content != relexed[i].Content ||
+			token.ValueInt != relexed[i].ValueInt || token.ValueFloat != relexed[i].ValueFloat {
+			return fmt.Errorf("Formatting changed '%s' at line %d", token.Content, token.Line())
+		}
+	}
+	for i, comment := range comments {
+		if comment.Content != recomments[i].Content {
+			return fmt.Errorf("Formatting changed the comment at line %d", comment.Span.Start.Line)
+		}
+	}
+	reparsed, err := ast.Parse(relexed)
+	if err != nil || !reflect.DeepEqual(ast.WithoutPositions(reparsed), ast.WithoutPositions(program)) {
+		return fmt.Errorf("Formatting changed the program")
+	}
+	return nil
+}
+
+type printer struct {
+	code     string
+	tokens   []tokens.Token
+	comments []tokens.Comment
+	// starts contains the offsets of the first tokens of statements
+	starts  map[int]bool
+	newline string
+
+	out   strings.Builder
+	depth int
+	// lineStart is set if nothing was written to the current line yet
+	lineStart bool
+	// line is the source line the last written token or comment ended in
+	line int
+	// opened is set if the last written token is a {
+	opened bool
+	// commented is set if the last written thing is a comment
+	commented bool
+}
+
+// statements records where the statements of the block and their bodies start.
+func (P *printer) statements(block ast.Block) {
+	for _, node := range block.Body {
+		P.statement(node)
+	}
+}
+
+func (P *printer) statement(node ast.Node) {
+	if position := ast.PositionOf(node); position.Line > 0 {
+		P.starts[position.Offset] = true
+	}
+	switch n := node.(type) {
+	case ast.Block:
+		P.statements(n)
+	case ast.Scoped:
+		P.statements(n.Body)
+	case ast.As:
+		P.statements(n.Body)
+	case ast.If:
+		P.statements(n.Body)
+		if n.Else != nil {
+			P.statement(n.Else)
+		}
+	case ast.While:
+		P.statements(n.Body)
+	case ast.For:
+		P.statements(n.Body)
+	case ast.Repeat:
+		P.statements(n.Body)
+	case ast.Func:
+		P.statements(n.Body)
+	}
+}
+
+// print writes the tokens and comments in the order of the source code.
+func (P *printer) print() string {
+	P.lineStart = true
+	comment := 0
+	for i, token := range P.tokens {
+		for comment < len(P.comments) && P.comments[comment].Span.Start.Offset < token.Span.Start.Offset {
+			next := token.Line()
+			if comment+1 < len(P.comments) && P.comments[comment+1].Span.Start.Offset < token.Span.Start.Offset {
+				next = P.comments[comment+1].Span.Start.Line
+			}
+			P.comment(P.comments[comment], next)
+			comment++
+		}
+		P.token(i)
+	}
+	for ; comment < len(P.comments); comment++ {
+		next := 0
+		if comment+1 < len(P.comments) {
+			next = P.comments[comment+1].Span.Start.Line
+		}
+		P.comment(P.comments[comment], next)
+	}
+	if !P.lineStart {
+		P.out.WriteString(P.newline)
+	}
+	return P.out.String()
+}
+
+// comment writes the comment behind the token on its line or on its own line.
+// A block comment followed by code or another comment on the line it ends in, which is next, doesn't end the line.
+func (P *printer) comment(comment tokens.Comment, next int) {
+	if !P.lineStart && comment.Span.Start.Line == P.line {
+		P.out.WriteString(" " + comment.Content)
+	} else {
+		if !P.lineStart {
+			P.out.WriteString(P.newline)
+		}
+		P.indent(comment.Span.Start.Line, P.depth, true)
+		P.out.WriteString(comment.Content)
+		P.opened = false
+	}
+	P.line = comment.Span.End.Line
+	P.commented = true
+	if strings.HasPrefix(comment.Content, "/*") && next == P.line {
+		return
+	}
+	P.out.WriteString(P.newline)
+	P.lineStart = true
+}
+
+// token writes the token at index i, starting a new line before statements and around braces.
+func (P *printer) token(i int) {
+	token := P.tokens[i]
+	if token.Type == tokens.ScopeClosed && P.depth > 0 {
+		P.depth--
+	}
+	// the first token only follows a block comment on its line, which is ended like before other statements
+	if !P.lineStart && (i == 0 || P.breaks(P.tokens[i-1], token)) {
+		P.out.WriteString(P.newline)
+		P.lineStart = true
+	}
+	if P.lineStart {
+		depth := P.depth
+		if i > 0 && !P.starts[token.Span.Start.Offset] && token.Type != tokens.ScopeClosed && token.Type != tokens.Else {
+			depth++
+		}
+		P.indent(token.Span.Start.Line, depth, token.Type != tokens.ScopeClosed)
+	} else if P.commented || !P.joined(i) {
+		P.out.WriteString(" ")
+	}
+	P.out.WriteString(P.code[token.Span.Start.Offset:token.Span.End.Offset])
+	P.lineStart = false
+	P.commented = false
+	P.line = token.Span.End.Line
+	P.opened = token.Type == tokens.ScopeOpen
+	if P.opened {
+		P.depth++
+	}
+}
+
+// indent starts a line at the depth.
+// If blank is set the line is separated by a blank line if it was in the source, except at the start of a block.
+func (P *printer) indent(line, depth int, blank bool) {
+	if blank && P.out.Len() > 0 && !P.opened && line-P.line > 1 {
+		P.out.WriteString(P.newline)
+	}
+	P.out.WriteString(strings.Repeat(indentation, depth))
+	P.lineStart = false
+}
+
+// breaks reports whether the token starts a new line after the previous one.
+// Statements start new lines unless they follow an else, bodies are written between lines of their braces.
+func (P *printer) breaks(previous, token tokens.Token) bool {
+	switch {
+	case P.starts[token.Span.Start.Offset]:
+		return previous.Type != tokens.Else
+	case previous.Type == tokens.ScopeOpen:
+		return token.Type != tokens.ScopeClosed
+	case token.Type == tokens.ScopeClosed:
+		return true
+	case previous.Type == tokens.ScopeClosed:
+		return token.Type != tokens.Else
+	}
+	return false
+}
+
+// joined reports whether the token at i is written without space to the previous token.
+func (P *printer) joined(i int) bool {
+	previous, token := P.tokens[i-1], P.tokens[i]
+	switch {
+	case previous.Type == tokens.ParenOpen || previous.Type == tokens.IndexOpen || previous.Type == tokens.Range:
+		return true
+	case token.Type == tokens.ParenClosed || token.Type == tokens.IndexClosed || token.Type == tokens.Comma || token.Type == tokens.Range:
+		return true
+	case token.Type == tokens.IndexOpen:
+		return true
+	case token.Type == tokens.ParenOpen:
+		return previous.Type == tokens.Identifier
+	case token.Type == tokens.ScopeClosed:
+		return previous.Type == tokens.ScopeOpen
+	case token.Type == tokens.OperationAssignment:
+		return token.ValueInt == tokens.OperationInc || token.ValueInt == tokens.OperationDec
+	case previous.Type == tokens.Operation && previous.ValueInt == tokens.OperationSub:
+		// a minus not following a value negates the value, a second minus stays separated so it isn't read as --
+		return (i < 2 || !value(P.tokens[i-2])) && token.Type != tokens.Operation
+	}
+	return false
+}
+
+// value reports whether the token ends a value.
+func value(token tokens.Token) bool {
+	switch token.Type {
+	case tokens.Identifier, tokens.Integer, tokens.Float, tokens.String, tokens.ParenClosed, tokens.IndexClosed:
+		return true
+	}
+	return false
+}
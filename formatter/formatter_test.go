@@ -0,0 +1,108 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			"spacing",
+			"s[x]=10*(2+ s[y])-add( 1 ,-2)\ns[i] ++\nfor i in 0 .. 3 {}",
+			"s[x] = 10 * (2 + s[y]) - add(1, -2)\ns[i]++\nfor i in 0..3 {}\n",
+		},
+		{
+			"negation",
+			"s[x] = - -1 - - s[y]",
+			"s[x] = - -1 - -s[y]\n",
+		},
+		{
+			"blocks",
+			"if s[x]==1{'say a' s[y]=2}\nelse if s[x]==2 { }\nelse{\n\t\t'say c'}",
+			"if s[x] == 1 {\n  'say a'\n  s[y] = 2\n} else if s[x] == 2 {} else {\n  'say c'\n}\n",
+		},
+		{
+			"comments",
+			"// header\n\n\n\ncreate store s // store\nfunc f(a) { // body\n\n  return a\n  // end\n}\n// footer",
+			"// header\n\ncreate store s // store\nfunc f(a) { // body\n  return a\n  // end\n}\n// footer\n",
+		},
+		{
+			"block comments",
+			"/* header\n   lines */\ns[x] = /* inline */ 1\n  /* own */ s[y]=2",
+			"/* header\n   lines */\ns[x] = /* inline */ 1\n/* own */\ns[y] = 2\n",
+		},
+		{
+			"literals",
+			"s[x]=1_000\n'execute at @a'{'say \\t'}",
+			"s[x] = 1_000\n'execute at @a' {\n  'say \\t'\n}\n",
+		},
+		{
+			"comment before first statement",
+			"/* header */ create store s",
+			"/* header */\ncreate store s\n",
+		},
+		{
+			"comments before statement",
+			"s[x]++\n/* b *//* b */'say hi'",
+			"s[x]++\n/* b */ /* b */\n'say hi'\n",
+		},
+		{
+			"continued expression",
+			"s[x] = 1\n-1\ns[y] = 2",
+			"s[x] = 1 - 1\ns[y] = 2\n",
+		},
+		{
+			"crlf",
+			"s[x] = 1\r\n\r\ns[y] = 2",
+			"s[x] = 1\r\n\r\ns[y] = 2\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format(tt.code)
+			if err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+			again, err := Format(got)
+			if err != nil || again != got {
+				t.Errorf("Format() isn't stable, got %q, error = %v", again, err)
+			}
+		})
+	}
+}
+
+// TestFormat_Verify checks that formatting which parses into another program is rejected.
+func TestFormat_Verify(t *testing.T) {
+	code := "s[x] = 1\n-1"
+	lexed, comments, err := tokens.LexerComments(code)
+	if err != nil {
+		t.Fatalf("LexerComments() error = %v", err)
+	}
+	split := ast.Block{Body: []ast.Node{ast.MakeStoreAssign("s", "x", true, tokens.OperationSet, ast.Int{Value: 1}), ast.Int{Value: -1}}}
+	if err := verify(lexed, comments, split, "s[x] = 1 - 1\n"); err == nil {
+		t.Errorf("verify() of joined statements error = nil, want error")
+	}
+}
+
+func TestFormat_SyntaxError(t *testing.T) {
+	_, err := Format("s[x] = (1 +")
+	if _, ok := diag.From(err); !ok {
+		t.Errorf("Format() error = %v, want diagnostics", err)
+	}
+	// the syntax errors are reported even if the lexer found errors
+	_, err = Format("s[x] = 1.2.3\ns[y] = )\n'say hi")
+	if list, _ := diag.From(err); len(list) != 3 || list[0].Code != diag.InvalidNumber || list[1].Code != diag.ExpectedValue {
+		t.Errorf("Format() error = %v, want lexer and parser diagnostics", err)
+	}
+}
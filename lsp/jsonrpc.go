@@ -0,0 +1,123 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Error codes of json-rpc.
+const (
+	parseError     = -32700
+	methodNotFound = -32601
+	invalidParams  = -32602
+)
+
+// message is a json-rpc request, notification or response.
+// Notifications have no ID, responses have no method.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (R *responseError) Error() string {
+	return R.Message
+}
+
+// conn reads and writes messages framed by a Content-Length header.
+type conn struct {
+	reader *bufio.Reader
+	writer io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{bufio.NewReader(r), w}
+}
+
+// read reads the next message, io.EOF is returned if the stream ended before it.
+func (C *conn) read() (message, error) {
+	length := -1
+	for {
+		line, err := C.reader.ReadString('\n')
+		if err == io.EOF && line == "" && length < 0 {
+			return message{}, io.EOF
+		}
+		if err != nil {
+			return message{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		separator := strings.Index(line, ":")
+		if separator >= 0 && strings.EqualFold(line[:separator], "Content-Length") {
+			value := strings.TrimSpace(line[separator+1:])
+			length, err = strconv.Atoi(value)
+			if err != nil {
+				return message{}, fmt.Errorf("Invalid Content-Length %s", value)
+			}
+		}
+	}
+	if length < 0 {
+		return message{}, fmt.Errorf("Missing Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(C.reader, body)
+	if err != nil {
+		return message{}, err
+	}
+	msg := message{}
+	err = json.Unmarshal(body, &msg)
+	if err != nil {
+		return message{}, &responseError{parseError, err.Error()}
+	}
+	return msg, nil
+}
+
+// write writes the message with its header.
+func (C *conn) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(C.writer, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// notify sends a notification with the params.
+func (C *conn) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return C.write(message{Method: method, Params: raw})
+}
+
+// respond answers the request with id with the result or the error.
+func (C *conn) respond(id json.RawMessage, result interface{}, err error) error {
+	if err != nil {
+		responseErr, ok := err.(*responseError)
+		if !ok {
+			responseErr = &responseError{invalidParams, err.Error()}
+		}
+		return C.write(message{ID: id, Error: responseErr})
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return C.write(message{ID: id, Result: raw})
+}
@@ -0,0 +1,197 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/worldOneo/datapacklang/diag"
+)
+
+// The subset of the language server protocol types used by the server.
+
+// Position is a zero based line and character in UTF-16 code units.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type InitializeParams struct {
+	RootURI string `json:"rootUri"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   ServerInfo         `json:"serverInfo"`
+}
+
+type ServerInfo struct {
+	Name string `json:"name"`
+}
+
+type ServerCapabilities struct {
+	TextDocumentSync   TextDocumentSyncOptions `json:"textDocumentSync"`
+	DefinitionProvider bool                    `json:"definitionProvider"`
+	ReferencesProvider bool                    `json:"referencesProvider"`
+	HoverProvider      bool                    `json:"hoverProvider"`
+	CompletionProvider CompletionOptions       `json:"completionProvider"`
+}
+
+// Kinds of text document synchronization.
+const (
+	syncFull = 1
+)
+
+type TextDocumentSyncOptions struct {
+	OpenClose bool `json:"openClose"`
+	Change    int  `json:"change"`
+	Save      bool `json:"save"`
+}
+
+type CompletionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentItem struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   TextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    Range         `json:"range"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Kinds of completion items.
+const (
+	completionField    = 5
+	completionVariable = 6
+	completionKeyword  = 14
+)
+
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Severities of diagnostics.
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+// protocolPosition converts the position in the text to a protocol position.
+func protocolPosition(text string, position diag.Position) Position {
+	if position.Line < 1 || position.Offset > len(text) {
+		return Position{}
+	}
+	start := strings.LastIndexAny(text[:position.Offset], "\r\n") + 1
+	return Position{position.Line - 1, utf16Length(text[start:position.Offset])}
+}
+
+// protocolRange converts the span in the text to a protocol range.
+func protocolRange(text string, span diag.Span) Range {
+	return Range{protocolPosition(text, span.Start), protocolPosition(text, span.End)}
+}
+
+// offset returns the byte offset of the protocol position in the text.
+// Positions behind the end of a line are moved to its end.
+func offset(text string, position Position) int {
+	line := 0
+	i := 0
+	for i < len(text) && line < position.Line {
+		switch {
+		case strings.HasPrefix(text[i:], "\r\n"):
+			i++
+			line++
+		case text[i] == '\n' || text[i] == '\r':
+			line++
+		}
+		i++
+	}
+	units := 0
+	for i < len(text) && units < position.Character {
+		c, size := utf8.DecodeRuneInString(text[i:])
+		if c == '\n' || c == '\r' {
+			break
+		}
+		units += utf16Length(string(c))
+		i += size
+	}
+	return i
+}
+
+func utf16Length(s string) int {
+	length := 0
+	for _, c := range s {
+		if c >= 0x10000 {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
@@ -0,0 +1,298 @@
+// Package lsp implements a language server for .dpl files speaking the language server protocol over a stream.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+	"github.com/worldOneo/datapacklang/translator"
+)
+
+// keywords are completed everywhere outside of an index.
+var keywords = []string{"as", "create", "else", "for", "func", "if", "in", "not", "repeat", "return", "store", "while"}
+
+// Server answers the requests of one client.
+// The workspace is compiled like the compiler does with the options, so hovers show the names of the build.
+type Server struct {
+	conn    *conn
+	options translator.Options
+	// root is the directory of the workspace, empty if the client didn't send one
+	root string
+	// documents are the texts of the open documents by their path
+	documents map[string]string
+	analysis  analysis
+	// published are the paths diagnostics were published for
+	published map[string]bool
+	shutdown  bool
+	exit      bool
+}
+
+func NewServer(r io.Reader, w io.Writer, options translator.Options) *Server {
+	return &Server{
+		conn:      newConn(r, w),
+		options:   options,
+		documents: make(map[string]string),
+		published: make(map[string]bool),
+	}
+}
+
+// Serve handles messages until the client sends exit or closes the stream.
+// An error is returned if the client exits without shutting the server down first.
+func (S *Server) Serve() error {
+	for !S.exit {
+		msg, err := S.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if responseErr, ok := err.(*responseError); ok {
+			err = S.conn.respond(json.RawMessage("null"), nil, responseErr)
+		}
+		if err != nil {
+			return err
+		}
+		err = S.handle(msg)
+		if err != nil {
+			return err
+		}
+	}
+	if !S.shutdown {
+		return fmt.Errorf("Exit without shutdown")
+	}
+	return nil
+}
+
+// handle dispatches the message, requests are answered and notifications only change the state.
+func (S *Server) handle(msg message) error {
+	var result interface{}
+	var err error
+	switch msg.Method {
+	case "initialize":
+		result, err = S.initialize(msg.Params)
+	case "shutdown":
+		S.shutdown = true
+	case "exit":
+		S.exit = true
+	case "textDocument/didOpen":
+		params := DidOpenTextDocumentParams{}
+		if json.Unmarshal(msg.Params, &params) == nil {
+			S.documents[path(params.TextDocument.URI)] = params.TextDocument.Text
+			return S.publish()
+		}
+	case "textDocument/didChange":
+		params := DidChangeTextDocumentParams{}
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			S.documents[path(params.TextDocument.URI)] = params.ContentChanges[len(params.ContentChanges)-1].Text
+			S.analyze()
+		}
+	case "textDocument/didSave":
+		return S.publish()
+	case "textDocument/didClose":
+		params := DidCloseTextDocumentParams{}
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(S.documents, path(params.TextDocument.URI))
+			return S.publish()
+		}
+	case "textDocument/definition":
+		result, err = S.definition(msg.Params)
+	case "textDocument/references":
+		result, err = S.references(msg.Params)
+	case "textDocument/hover":
+		result, err = S.hover(msg.Params)
+	case "textDocument/completion":
+		result, err = S.completion(msg.Params)
+	default:
+		err = &responseError{methodNotFound, fmt.Sprintf("Unknown method %s", msg.Method)}
+	}
+	if msg.ID == nil {
+		return nil
+	}
+	return S.conn.respond(msg.ID, result, err)
+}
+
+func (S *Server) initialize(raw json.RawMessage) (InitializeResult, error) {
+	params := InitializeParams{}
+	err := json.Unmarshal(raw, &params)
+	if err != nil {
+		return InitializeResult{}, err
+	}
+	if params.RootURI != "" {
+		S.root = path(params.RootURI)
+	}
+	return InitializeResult{
+		ServerCapabilities{
+			TextDocumentSync:   TextDocumentSyncOptions{OpenClose: true, Change: syncFull, Save: true},
+			DefinitionProvider: true,
+			ReferencesProvider: true,
+			HoverProvider:      true,
+			CompletionProvider: CompletionOptions{[]string{"["}},
+		},
+		ServerInfo{"dpl"},
+	}, nil
+}
+
+func (S *Server) analyze() {
+	S.analysis = analyze(S.root, S.documents, S.options)
+}
+
+// publish compiles the workspace and sends the diagnostics of every file.
+// Files which had diagnostics before get an empty list so the client clears them.
+func (S *Server) publish() error {
+	S.analyze()
+	published := make(map[string]bool)
+	for _, f := range S.analysis.files {
+		if len(f.diagnostics) == 0 && !S.published[f.path] {
+			continue
+		}
+		diagnostics := make([]Diagnostic, len(f.diagnostics))
+		for i, diagnostic := range f.diagnostics {
+			diagnostics[i] = convert(f.text, diagnostic)
+		}
+		err := S.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{uri(f.path), diagnostics})
+		if err != nil {
+			return err
+		}
+		published[f.path] = len(diagnostics) > 0
+	}
+	for path := range S.published {
+		if _, ok := published[path]; !ok {
+			err := S.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{uri(path), []Diagnostic{}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	S.published = published
+	return nil
+}
+
+func convert(text string, diagnostic diag.Diagnostic) Diagnostic {
+	severity := severityError
+	if diagnostic.Severity == diag.Warning {
+		severity = severityWarning
+	}
+	return Diagnostic{protocolRange(text, diagnostic.Span), severity, diagnostic.Code.String(), "dpl", diagnostic.Message}
+}
+
+// symbol returns the file at the position and the symbol at it.
+func (S *Server) symbol(params TextDocumentPositionParams) (*file, symbol, bool) {
+	f := S.analysis.file(path(params.TextDocument.URI))
+	if f == nil {
+		return nil, symbol{}, false
+	}
+	s, ok := f.symbolAt(offset(f.text, params.Position))
+	return f, s, ok
+}
+
+func (S *Server) definition(raw json.RawMessage) (*Location, error) {
+	params := TextDocumentPositionParams{}
+	err := json.Unmarshal(raw, &params)
+	if err != nil {
+		return nil, err
+	}
+	_, target, ok := S.symbol(params)
+	if !ok {
+		return nil, nil
+	}
+	location, ok := S.analysis.definition(target)
+	if !ok {
+		return nil, nil
+	}
+	return &location, nil
+}
+
+func (S *Server) references(raw json.RawMessage) ([]Location, error) {
+	params := ReferenceParams{}
+	err := json.Unmarshal(raw, &params)
+	if err != nil {
+		return nil, err
+	}
+	_, target, ok := S.symbol(params.TextDocumentPositionParams)
+	if !ok {
+		return []Location{}, nil
+	}
+	locations := S.analysis.references(target)
+	if params.Context.IncludeDeclaration {
+		return locations, nil
+	}
+	definition, _ := S.analysis.definition(target)
+	filtered := make([]Location, 0, len(locations))
+	for _, location := range locations {
+		if location != definition {
+			filtered = append(filtered, location)
+		}
+	}
+	return filtered, nil
+}
+
+// hover shows the objective or fake player the store or variable is compiled to.
+func (S *Server) hover(raw json.RawMessage) (*Hover, error) {
+	params := TextDocumentPositionParams{}
+	err := json.Unmarshal(raw, &params)
+	if err != nil {
+		return nil, err
+	}
+	f, target, ok := S.symbol(params)
+	if !ok {
+		return nil, nil
+	}
+	kind := "objective"
+	if target.kind == variableSymbol {
+		kind = "fake player"
+	}
+	value := fmt.Sprintf("%s `%s` isn't compiled", target.kind, target.name)
+	if name, ok := S.analysis.compiled(target); ok {
+		value = fmt.Sprintf("%s `%s` is the %s `%s`", target.kind, target.name, kind, name)
+	}
+	return &Hover{MarkupContent{"markdown", value}, protocolRange(f.text, target.span)}, nil
+}
+
+// completion completes variables inside an index and keywords and created stores everywhere else.
+func (S *Server) completion(raw json.RawMessage) ([]CompletionItem, error) {
+	params := TextDocumentPositionParams{}
+	err := json.Unmarshal(raw, &params)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]CompletionItem, 0)
+	f := S.analysis.file(path(params.TextDocument.URI))
+	if f == nil {
+		return items, nil
+	}
+	if inIndex(f.tokens, offset(f.text, params.Position)) {
+		for _, name := range S.analysis.variables() {
+			detail, _ := S.analysis.compiled(symbol{kind: variableSymbol, name: name})
+			items = append(items, CompletionItem{name, completionField, detail})
+		}
+		return items, nil
+	}
+	for _, name := range S.analysis.stores() {
+		detail, _ := S.analysis.compiled(symbol{kind: storeSymbol, name: name})
+		items = append(items, CompletionItem{name, completionVariable, detail})
+	}
+	for _, keyword := range keywords {
+		items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+	return items, nil
+}
+
+// inIndex reports whether the offset directly follows a [ or is inside the identifier following it.
+func inIndex(lexed []tokens.Token, offset int) bool {
+	previous := -1
+	for i, token := range lexed {
+		if token.Span.Start.Offset >= offset {
+			break
+		}
+		previous = i
+	}
+	if previous < 0 {
+		return false
+	}
+	if lexed[previous].Type == tokens.IndexOpen {
+		return lexed[previous].Span.End.Offset <= offset
+	}
+	return lexed[previous].Type == tokens.Identifier && previous > 0 && lexed[previous-1].Type == tokens.IndexOpen &&
+		offset <= lexed[previous].Span.End.Offset
+}
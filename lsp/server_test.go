@@ -0,0 +1,191 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/worldOneo/datapacklang/translator"
+)
+
+// client is a scripted client talking to a server over pipes.
+type client struct {
+	t    *testing.T
+	conn *conn
+	id   int
+	// notifications received while waiting for responses
+	notifications []message
+}
+
+func start(t *testing.T, options translator.Options) (*client, chan error) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(serverIn, serverOut, options).Serve()
+		serverOut.Close()
+	}()
+	return &client{t: t, conn: newConn(clientIn, clientOut)}, done
+}
+
+// request sends the request and unmarshals the result of its response into result.
+func (C *client) request(method string, params interface{}, result interface{}) {
+	C.t.Helper()
+	C.id++
+	raw, _ := json.Marshal(params)
+	id, _ := json.Marshal(C.id)
+	err := C.conn.write(message{ID: id, Method: method, Params: raw})
+	if err != nil {
+		C.t.Fatalf("write %s: %v", method, err)
+	}
+	for {
+		msg, err := C.conn.read()
+		if err != nil {
+			C.t.Fatalf("read %s: %v", method, err)
+		}
+		if msg.ID == nil {
+			C.notifications = append(C.notifications, msg)
+			continue
+		}
+		if msg.Error != nil {
+			C.t.Fatalf("%s: %v", method, msg.Error)
+		}
+		if result != nil {
+			err = json.Unmarshal(msg.Result, result)
+			if err != nil {
+				C.t.Fatalf("unmarshal %s: %v", method, err)
+			}
+		}
+		return
+	}
+}
+
+func (C *client) notify(method string, params interface{}) {
+	C.t.Helper()
+	err := C.conn.notify(method, params)
+	if err != nil {
+		C.t.Fatalf("write %s: %v", method, err)
+	}
+}
+
+// diagnostics waits for the next published diagnostics.
+func (C *client) diagnostics() PublishDiagnosticsParams {
+	C.t.Helper()
+	msg := message{}
+	if len(C.notifications) > 0 {
+		msg, C.notifications = C.notifications[0], C.notifications[1:]
+	} else {
+		var err error
+		msg, err = C.conn.read()
+		if err != nil {
+			C.t.Fatalf("read diagnostics: %v", err)
+		}
+	}
+	params := PublishDiagnosticsParams{}
+	if msg.Method != "textDocument/publishDiagnostics" || json.Unmarshal(msg.Params, &params) != nil {
+		C.t.Fatalf("got %s, want diagnostics", msg.Method)
+	}
+	return params
+}
+
+const document = "file:///pack/main.dpl"
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocumentIdentifier{document}, Position{line, character}}
+}
+
+func span(line, start, end int) Location {
+	return Location{document, Range{Position{line, start}, Position{line, end}}}
+}
+
+func TestServer(t *testing.T) {
+	options := translator.DefaultOptions()
+	options.DebugNames = true
+	c, done := start(t, options)
+
+	initialized := InitializeResult{}
+	c.request("initialize", InitializeParams{}, &initialized)
+	if !initialized.Capabilities.HoverProvider || initialized.Capabilities.TextDocumentSync.Change != syncFull {
+		t.Errorf("initialize = %+v", initialized)
+	}
+	c.notify("initialized", struct{}{})
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocumentItem{document, "create store s\ns[x] = 1\ns[y] = s[x] + 2\ns[z] = f()\n"}})
+	published := c.diagnostics()
+	want := []Diagnostic{{Range{Position{3, 0}, Position{3, 1}}, severityError, "E201", "dpl", "Unknown func f"}}
+	if published.URI != document || !reflect.DeepEqual(published.Diagnostics, want) {
+		t.Errorf("publishDiagnostics = %+v, want %+v", published, want)
+	}
+
+	definition := Location{}
+	c.request("textDocument/definition", at(2, 9), &definition)
+	if want := span(1, 2, 3); definition != want {
+		t.Errorf("definition = %+v, want %+v", definition, want)
+	}
+
+	references := []Location{}
+	params := ReferenceParams{TextDocumentPositionParams: at(0, 13)}
+	params.Context.IncludeDeclaration = true
+	c.request("textDocument/references", params, &references)
+	wantReferences := []Location{span(0, 13, 14), span(1, 0, 1), span(2, 0, 1), span(2, 7, 8), span(3, 0, 1)}
+	if !reflect.DeepEqual(references, wantReferences) {
+		t.Errorf("references = %+v, want %+v", references, wantReferences)
+	}
+
+	hover := Hover{}
+	c.request("textDocument/hover", at(2, 10), &hover)
+	if want := "variable `x` is the fake player `x`"; hover.Contents.Value != want || hover.Range != span(2, 9, 10).Range {
+		t.Errorf("hover = %+v, want %s", hover, want)
+	}
+
+	completion := []CompletionItem{}
+	c.request("textDocument/completion", at(3, 2), &completion)
+	wantCompletion := []CompletionItem{{"x", completionField, "x"}, {"y", completionField, "y"}, {"z", completionField, "z"}}
+	if !reflect.DeepEqual(completion, wantCompletion) {
+		t.Errorf("completion in index = %+v, want %+v", completion, wantCompletion)
+	}
+	c.request("textDocument/completion", at(4, 0), &completion)
+	if len(completion) != len(keywords)+1 || completion[0] != (CompletionItem{"s", completionVariable, "s"}) {
+		t.Errorf("completion = %+v, want s and keywords", completion)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{document},
+		ContentChanges: []struct {
+			Text string `json:"text"`
+		}{{"create store s\ns[x] = 1\n"}},
+	})
+	c.notify("textDocument/didSave", DidSaveTextDocumentParams{TextDocumentIdentifier{document}})
+	if published := c.diagnostics(); len(published.Diagnostics) != 0 {
+		t.Errorf("publishDiagnostics after fix = %+v, want none", published)
+	}
+
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-done; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	text := "a\r\nb😀c\rd"
+	tests := []struct {
+		position Position
+		want     int
+	}{
+		{Position{0, 1}, 1},
+		{Position{1, 0}, 3},
+		{Position{1, 3}, 8},
+		{Position{1, 9}, 9},
+		{Position{2, 1}, 11},
+	}
+	for _, tt := range tests {
+		if got := offset(text, tt.position); got != tt.want {
+			t.Errorf("offset(%v) = %d, want %d", tt.position, got, tt.want)
+		}
+	}
+	if got := uri(path(document)); got != document {
+		t.Errorf("uri(path(%s)) = %s", document, got)
+	}
+}
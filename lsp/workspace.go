@@ -0,0 +1,259 @@
+package lsp
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+	"github.com/worldOneo/datapacklang/translator"
+)
+
+type symbolKind int
+
+const (
+	storeSymbol symbolKind = iota
+	variableSymbol
+)
+
+func (S symbolKind) String() string {
+	if S == variableSymbol {
+		return "variable"
+	}
+	return "store"
+}
+
+// symbol is an occurrence of the name of a store or variable in a file.
+type symbol struct {
+	kind symbolKind
+	name string
+	span diag.Span
+	// definition is set for the name of a create store and the index of an assignment with =
+	definition bool
+}
+
+// file is an analyzed .dpl file of the workspace.
+type file struct {
+	path        string
+	text        string
+	tokens      []tokens.Token
+	symbols     []symbol
+	diagnostics diag.List
+}
+
+// analysis is the result of compiling every file of the workspace like the compiler does.
+type analysis struct {
+	files    []*file
+	manifest translator.Manifest
+}
+
+// analyze compiles the .dpl files below the root and the open documents as one unit.
+// Open documents replace the content of the file on disk, without root only the documents are compiled.
+func analyze(root string, documents map[string]string, options translator.Options) analysis {
+	texts := make(map[string]string)
+	if root != "" {
+		filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(path) != ".dpl" {
+				return nil
+			}
+			content, err := ioutil.ReadFile(path)
+			if err == nil {
+				texts[path] = string(content)
+			}
+			return nil
+		})
+	}
+	for path, text := range documents {
+		texts[path] = text
+	}
+	paths := make([]string, 0, len(texts))
+	for path := range texts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	unit := translator.NewUnit(options)
+	files := make([]*file, len(paths))
+	for i, path := range paths {
+		files[i] = compile(root, path, texts[path], unit)
+	}
+	return analysis{files, unit.Manifest()}
+}
+
+// compile translates the text of the file with the unit and collects its symbols.
+// The file is only translated if it doesn't contain syntax errors.
+func compile(root, path, text string, unit *translator.Unit) *file {
+	lexed, lexErr := tokens.Lexer(text)
+	program, err := ast.Parse(lexed)
+	diagnostics, _ := diag.From(diag.Join(lexErr, err))
+	f := &file{path, text, lexed, symbols(lexed), diagnostics}
+	if len(diagnostics) > 0 {
+		return f
+	}
+	module := strings.TrimSuffix(filepath.Base(path), ".dpl")
+	if root != "" {
+		relative, err := filepath.Rel(root, strings.TrimSuffix(path, ".dpl"))
+		if err == nil {
+			module = filepath.ToSlash(relative)
+		}
+	}
+	_, err = unit.Translate(module, program, nil)
+	f.diagnostics, _ = diag.From(err)
+	return f
+}
+
+// symbols finds the names of stores and variables in the tokens.
+// A store is an identifier followed by [ or following create store, a variable is an identifier index.
+func symbols(lexed []tokens.Token) []symbol {
+	is := func(i int, tokenType tokens.TokenType) bool {
+		return i >= 0 && i < len(lexed) && lexed[i].Type == tokenType
+	}
+	found := make([]symbol, 0)
+	for i, token := range lexed {
+		if token.Type != tokens.Identifier {
+			continue
+		}
+		switch {
+		case is(i-2, tokens.Create) && is(i-1, tokens.Identifier) && lexed[i-1].Content == "store":
+			found = append(found, symbol{storeSymbol, token.Content, token.Span, true})
+		case is(i+1, tokens.IndexOpen):
+			found = append(found, symbol{storeSymbol, token.Content, token.Span, false})
+		case is(i-1, tokens.IndexOpen):
+			assigned := is(i+1, tokens.IndexClosed) && is(i+2, tokens.OperationAssignment) && lexed[i+2].ValueInt == tokens.OperationSet
+			found = append(found, symbol{variableSymbol, token.Content, token.Span, assigned})
+		}
+	}
+	return found
+}
+
+// file returns the analyzed file at path or nil.
+func (A analysis) file(path string) *file {
+	for _, f := range A.files {
+		if f.path == path {
+			return f
+		}
+	}
+	return nil
+}
+
+// symbolAt returns the symbol containing the offset or ending at it.
+func (F *file) symbolAt(offset int) (symbol, bool) {
+	for _, s := range F.symbols {
+		if s.span.Start.Offset <= offset && offset <= s.span.End.Offset {
+			return s, true
+		}
+	}
+	return symbol{}, false
+}
+
+// references returns the occurrences of the symbol in every file.
+func (A analysis) references(target symbol) []Location {
+	locations := make([]Location, 0)
+	for _, f := range A.files {
+		for _, s := range f.symbols {
+			if s.kind == target.kind && s.name == target.name {
+				locations = append(locations, f.location(s.span))
+			}
+		}
+	}
+	return locations
+}
+
+// definition returns the first definition of the symbol, a variable without assignment is defined by its first occurrence.
+func (A analysis) definition(target symbol) (Location, bool) {
+	var first *Location
+	for _, f := range A.files {
+		for _, s := range f.symbols {
+			if s.kind != target.kind || s.name != target.name {
+				continue
+			}
+			if s.definition {
+				return f.location(s.span), true
+			}
+			if first == nil && s.kind == variableSymbol {
+				location := f.location(s.span)
+				first = &location
+			}
+		}
+	}
+	if first == nil {
+		return Location{}, false
+	}
+	return *first, true
+}
+
+// compiled returns the generated name of the symbol.
+func (A analysis) compiled(target symbol) (string, bool) {
+	names := A.manifest.Stores
+	if target.kind == variableSymbol {
+		names = A.manifest.Variables
+	}
+	name, ok := names[target.name]
+	return name, ok
+}
+
+// stores returns the names of the stores created in any file.
+func (A analysis) stores() []string {
+	found := make(map[string]bool)
+	for _, f := range A.files {
+		for _, s := range f.symbols {
+			if s.kind == storeSymbol && s.definition {
+				found[s.name] = true
+			}
+		}
+	}
+	return sorted(found)
+}
+
+// variables returns the names of the variables used in any file.
+func (A analysis) variables() []string {
+	found := make(map[string]bool)
+	for _, f := range A.files {
+		for _, s := range f.symbols {
+			if s.kind == variableSymbol {
+				found[s.name] = true
+			}
+		}
+	}
+	return sorted(found)
+}
+
+func sorted(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (F *file) location(span diag.Span) Location {
+	return Location{uri(F.path), protocolRange(F.text, span)}
+}
+
+// uri converts the path of a file to a file uri.
+// Windows paths like C:/main.dpl get a leading slash.
+func uri(path string) string {
+	slashed := filepath.ToSlash(path)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+// path converts a file uri to the path of the file.
+func path(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+	if filepath.VolumeName(strings.TrimPrefix(parsed.Path, "/")) != "" {
+		return filepath.FromSlash(strings.TrimPrefix(parsed.Path, "/"))
+	}
+	return filepath.FromSlash(parsed.Path)
+}
@@ -1,9 +1,11 @@
 package tokens
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/worldOneo/datapacklang/diag"
 )
 
 type TokenType = uint32
@@ -14,7 +16,25 @@ type Token struct {
 	Content    string
 	ValueInt   int
 	ValueFloat float64
-	Line       int
+	Span       Span
+	// Doc is the text of the /// comments directly in front of the token
+	Doc string
+}
+
+// Position is a location in the source code.
+type Position = diag.Position
+
+// Span is the range of source code from Start up to, but excluding, End.
+type Span = diag.Span
+
+// Position returns the position the token starts at.
+func (T Token) Position() Position {
+	return T.Span.Start
+}
+
+// Line returns the line the token starts in.
+func (T Token) Line() int {
+	return T.Span.Start.Line
 }
 
 const (
@@ -36,6 +56,14 @@ const (
 	If
 	As
 	Not
+	Else
+	Func
+	Return
+	While
+	For
+	In
+	Repeat
+	Range
 )
 
 const (
@@ -83,18 +111,38 @@ func init() {
 	comparatorMap["=="] = OperationEq
 	comparatorMap["<="] = OperationLte
 	comparatorMap["<"] = OperationLt
+	comparatorMap["!="] = OperationNeq
 }
 
 const windowsLineSpererator = "\r\n"
 const commentIntroduction = "//"
+const docIntroduction = "///"
 
 type CodeLexer struct {
-	code        []rune
-	words       []Token
+	code       []rune
+	words      []Token
 	tokenIndex int
+	// positions contains the position of every rune of the code and the end of the code
+	positions   []Position
+	diagnostics diag.List
+	comments    []Comment
+	// doc are the lines of the /// comments in front of the next token
+	doc []string
+}
+
+// Comment is a // or /* */ comment of the code, the content includes the comment signs.
+type Comment struct {
+	Content string
+	Span    Span
 }
 
-func (C *CodeLexer) append(word Token) {
+// append adds the token which spans the runes from start up to, but excluding, end.
+func (C *CodeLexer) append(word Token, start, end int) {
+	word.Span = Span{Start: C.positions[start], End: C.positions[end]}
+	if len(C.doc) > 0 {
+		word.Doc = strings.Join(C.doc, "\n")
+		C.doc = nil
+	}
 	C.words[C.tokenIndex] = word
 	C.tokenIndex++
 	if C.tokenIndex >= len(C.words) {
@@ -119,23 +167,53 @@ func Lexerp(code string) []Token {
 	return a
 }
 
+// Lexer splits the code into tokens, the returned error is a diag.List.
+// The tokens are returned even if errors are found, invalid literals as zero values,
+// so the parser can still report the syntax errors of the code.
 func Lexer(code string) ([]Token, error) {
+	words, _, err := LexerComments(code)
+	return words, err
+}
+
+// LexerComments splits the code into tokens like Lexer and also returns the comments in the order they appear in.
+func LexerComments(code string) ([]Token, []Comment, error) {
 	parser := CodeLexer{
 		[]rune(code),
 		make([]Token, 64),
 		0,
+		nil,
+		nil,
+		make([]Comment, 0),
+		nil,
 	}
 	words, err := parser.Lexer()
-	if err != nil {
-		return words, err
+	return words[0:parser.tokenIndex], parser.comments, err
+}
+
+// locate computes the position of every rune.
+// \r\n, \n and a single \r end a line.
+func (C *CodeLexer) locate() {
+	C.positions = make([]Position, len(C.code)+1)
+	position := Position{Line: 1, Column: 1, Offset: 0}
+	for i, c := range C.code {
+		C.positions[i] = position
+		position.Offset += utf8.RuneLen(c)
+		n, _ := Peek(C.code, i+1)
+		if c == '\n' || (c == '\r' && n != '\n') {
+			position.Line++
+			position.Column = 1
+		} else {
+			position.Column++
+		}
 	}
-	return words[0:parser.tokenIndex], err
+	C.positions[len(C.code)] = position
 }
 
+// Lexer splits the code into tokens.
+// Invalid literals are reported and skipped, the returned error is a diag.List of all of them.
 func (C *CodeLexer) Lexer() ([]Token, error) {
-	lineComment := false
-	line := 0
 	buff := strings.Builder{}
+	C.locate()
 
 	for i := 0; i < len(C.code); i++ {
 		c := C.code[i]
@@ -146,63 +224,86 @@ func (C *CodeLexer) Lexer() ([]Token, error) {
 			}
 			return 0, false
 		}
-		n, peeked := Peek(C.code, i+1)
-		if isNewLine(c) {
-			line++
-			lineComment = false
+		n, _ := Peek(C.code, i+1)
+		if isSpace(c) {
 			continue
 		}
 
-		if isSpace(c) || lineComment {
+		if isLineComment(c, n) {
+			end := i
+			for end < len(C.code) && !isNewLine(C.code[end]) {
+				end++
+			}
+			content := strings.TrimRight(string(C.code[i:end]), " \t")
+			span := Span{Start: C.positions[i], End: C.positions[i+len([]rune(content))]}
+			C.comments = append(C.comments, Comment{content, span})
+			if isDocComment(content) {
+				C.doc = append(C.doc, strings.TrimPrefix(strings.TrimPrefix(content, docIntroduction), " "))
+			} else {
+				C.doc = nil
+			}
+			i = end - 1
 			continue
 		}
 
-		if isLineComment(c, n) {
-			lineComment = true
+		if isBlockCommentStart(c, n) {
+			end, closed := C.blockComment(i)
+			if !closed {
+				C.report(diag.UnterminatedComment, i, len(C.code), "Unterminated block comment")
+				break
+			}
+			span := Span{Start: C.positions[i], End: C.positions[end]}
+			C.comments = append(C.comments, Comment{string(C.code[i:end]), span})
+			C.doc = nil
+			i = end - 1
+			continue
 		}
 
 		if isSpecialChar(c) {
 			switch c {
 			case '{':
-				C.append(scopeOpenToken(line))
+				C.append(scopeOpenToken(), i, i+1)
 			case '}':
-				C.append(scopeClosedToken(line))
+				C.append(scopeClosedToken(), i, i+1)
 			case '(':
-				C.append(Token{ParenOpen, "(", 0, 0, line})
+				C.append(Token{Type: ParenOpen, Content: "("}, i, i+1)
 			case ')':
-				C.append(Token{ParenClosed, ")", 0, 0, line})
+				C.append(Token{Type: ParenClosed, Content: ")"}, i, i+1)
 			case ',':
-				C.append(Token{Comma, ",", 0, 0, line})
-			case '+', '-', '/', '*', '%', '=', '>', '<':
+				C.append(Token{Type: Comma, Content: ","}, i, i+1)
+			case '+', '-', '/', '*', '%', '=', '>', '<', '!':
 				sign := string(c)
 				if isSpecialChar(n) {
-					sign += string(n)
+					if _, _, ok := lookupOperator(sign + string(n)); ok {
+						sign += string(n)
+					}
 				}
 
-				var ok bool
-				var operator int
-				if operator, ok = assignmentMap[sign]; ok {
-					C.append(Token{OperationAssignment, sign, operator, 0, line})
-				} else if operator, ok = comparatorMap[sign]; ok {
-					C.append(Token{OperationComp, sign, operator, 0, line})
-				} else if operator, ok = operationMap[sign]; ok {
-					C.append(Token{Operation, sign, operator, 0, line})
-				}
-				if ok {
+				if typ, operator, ok := lookupOperator(sign); ok {
+					C.append(Token{Type: typ, Content: sign, ValueInt: operator}, i, i+len(sign))
 					i += len(sign) - 1
+				} else {
+					C.report(diag.ExpectedToken, i, i+1, "Unknown operator %s", sign)
 				}
 				continue
 			case '[':
-				C.append(Token{IndexOpen, "[", 0, 0, line})
+				C.append(Token{Type: IndexOpen, Content: "["}, i, i+1)
 			case ']':
-				C.append(Token{IndexClosed, "]", 0, 0, line})
+				C.append(Token{Type: IndexClosed, Content: "]"}, i, i+1)
 			}
 			continue
 		}
 
+		if isRange(C.code, i) {
+			C.append(Token{Type: Range, Content: ".."}, i, i+2)
+			i++
+			continue
+		}
+
+		start := i
 		if isAlpha(c) {
 			buff.Reset()
-			for isAlpha(C.code[i]) {
+			for isAlpha(C.code[i]) && !isRange(C.code, i) {
 				buff.WriteRune(C.code[i])
 				_, ok := safeInc()
 				if !ok {
@@ -213,31 +314,54 @@ func (C *CodeLexer) Lexer() ([]Token, error) {
 			val := buff.String()
 			switch val {
 			case "create":
-				C.append(Token{Create, val, 0, 0, line})
+				C.append(Token{Type: Create, Content: val}, start, i+1)
 				continue
 			case "if":
-				C.append(Token{If, val, 0, 0, line})
+				C.append(Token{Type: If, Content: val}, start, i+1)
 				continue
 			case "as":
-				C.append(Token{As, val, 0, 0, line})
+				C.append(Token{Type: As, Content: val}, start, i+1)
 				continue
 			case "not":
-				C.append(Token{Not, val, 0, 0, line})
+				C.append(Token{Type: Not, Content: val}, start, i+1)
+				continue
+			case "else":
+				C.append(Token{Type: Else, Content: val}, start, i+1)
+				continue
+			case "func":
+				C.append(Token{Type: Func, Content: val}, start, i+1)
+				continue
+			case "return":
+				C.append(Token{Type: Return, Content: val}, start, i+1)
+				continue
+			case "while":
+				C.append(Token{Type: While, Content: val}, start, i+1)
+				continue
+			case "for":
+				C.append(Token{Type: For, Content: val}, start, i+1)
+				continue
+			case "in":
+				C.append(Token{Type: In, Content: val}, start, i+1)
+				continue
+			case "repeat":
+				C.append(Token{Type: Repeat, Content: val}, start, i+1)
 				continue
 			}
-			C.append(Token{Identifier, val, 0, 0, line})
+			C.append(Token{Type: Identifier, Content: val}, start, i+1)
 			continue
 		}
 
 		if isStringBegin(c) {
 			buff.Reset()
 			escaped := false
+			closed := false
 			for {
 				c, ok := safeInc()
 				if !ok {
 					break
 				}
 				if isStringBegin(c) {
+					closed = true
 					break
 				}
 				if escaped {
@@ -250,12 +374,13 @@ func (C *CodeLexer) Lexer() ([]Token, error) {
 					continue
 				}
 				buff.WriteRune(c)
-				n, peeked = Peek(C.code, i+1)
-				if !peeked {
-					return []Token{}, fmt.Errorf("Incomplete string")
-				}
 			}
-			C.append(Token{String, buff.String(), 0, 0, line})
+			if !closed {
+				C.report(diag.UnterminatedString, start, len(C.code), "Unterminated string")
+				C.append(stringToken(buff.String()), start, len(C.code))
+				break
+			}
+			C.append(stringToken(buff.String()), start, i+1)
 			continue
 		}
 
@@ -263,14 +388,13 @@ func (C *CodeLexer) Lexer() ([]Token, error) {
 			buff.Reset()
 			float := false
 			var ok bool
-			for isDigit(c) || isNumericalSkipChar(c) || c == '.' {
-				if isNumericalSkipChar(c) {
-					continue
-				}
+			for isDigit(c) || isNumericalSkipChar(c) || (c == '.' && !isRange(C.code, i)) {
 				if c == '.' {
 					float = true
 				}
-				buff.WriteRune(c)
+				if !isNumericalSkipChar(c) {
+					buff.WriteRune(c)
+				}
 				c, ok = safeInc()
 				if !ok {
 					break
@@ -281,20 +405,61 @@ func (C *CodeLexer) Lexer() ([]Token, error) {
 			if !float {
 				intVal, err := strconv.Atoi(str)
 				if err != nil {
-					return []Token{}, fmt.Errorf("Unparseble int literal")
+					C.report(diag.InvalidNumber, start, i+1, "Invalid int literal %s", str)
+					C.append(intToken(str, 0), start, i+1)
+					continue
 				}
-				C.append(intToken(str, intVal, line))
+				C.append(intToken(str, intVal), start, i+1)
 				continue
 			}
 			floatVal, err := strconv.ParseFloat(str, 64)
 			if err != nil {
-				return []Token{}, fmt.Errorf("Unparseble int literal")
+				C.report(diag.InvalidNumber, start, i+1, "Invalid float literal %s", str)
+				C.append(floatToken(str, 0), start, i+1)
+				continue
 			}
-			C.append(floatToken(str, floatVal, line))
+			C.append(floatToken(str, floatVal), start, i+1)
 			continue
 		}
 	}
-	return C.words, nil
+	return C.words, C.diagnostics.Err()
+}
+
+// blockComment returns the end of the block comment starting at start and whether it is closed.
+// Block comments can't be nested, a /* inside of one is reported.
+func (C *CodeLexer) blockComment(start int) (int, bool) {
+	for i := start + 2; i < len(C.code); i++ {
+		n, _ := Peek(C.code, i+1)
+		if C.code[i] == '*' && n == '/' {
+			return i + 2, true
+		}
+		if isBlockCommentStart(C.code[i], n) {
+			C.report(diag.NestedComment, i, i+2, "Block comments can't be nested")
+			i++
+		}
+	}
+	return len(C.code), false
+}
+
+// report adds an error for the runes from start up to, but excluding, end.
+func (C *CodeLexer) report(code diag.Code, start, end int, format string, args ...interface{}) {
+	span := Span{Start: C.positions[start], End: C.positions[end]}
+	C.diagnostics = append(C.diagnostics, diag.Errorf(code, span, format, args...))
+}
+
+// lookupOperator resolves a sign to its token type and operation.
+// Assignments take priority over comparators and operations.
+func lookupOperator(sign string) (TokenType, OperationType, bool) {
+	if operator, ok := assignmentMap[sign]; ok {
+		return OperationAssignment, operator, true
+	}
+	if operator, ok := comparatorMap[sign]; ok {
+		return OperationComp, operator, true
+	}
+	if operator, ok := operationMap[sign]; ok {
+		return Operation, operator, true
+	}
+	return 0, 0, false
 }
 
 func isNumericalSkipChar(b rune) bool {
@@ -320,7 +485,14 @@ func isSpecialChar(b rune) bool {
 		b == '(' || b == ')' ||
 		b == '[' || b == ']' ||
 		b == '+' || b == '-' ||
-		b == '>' || b == '<'
+		b == '>' || b == '<' || b == '!'
+}
+
+// isRange reports whether a range operator (..) starts at index.
+func isRange(runes []rune, index int) bool {
+	c, _ := Peek(runes, index)
+	n, _ := Peek(runes, index+1)
+	return c == '.' && n == '.'
 }
 
 func isSpace(b rune) bool {
@@ -335,6 +507,14 @@ func isLineComment(b rune, c rune) bool {
 	return b == c && b == '/'
 }
 
+func isBlockCommentStart(b rune, c rune) bool {
+	return b == '/' && c == '*'
+}
+
+func isDocComment(comment string) bool {
+	return strings.HasPrefix(comment, docIntroduction)
+}
+
 func isEscapeChar(b rune) bool {
 	return b == '\\'
 }
@@ -358,26 +538,26 @@ func getEscapedCharacter(b rune) rune {
 	}
 }
 
-func scopeOpenToken(line int) Token {
-	return Token{ScopeOpen, "{", 0, 0, line}
+func scopeOpenToken() Token {
+	return Token{Type: ScopeOpen, Content: "{"}
 }
 
-func scopeClosedToken(line int) Token {
-	return Token{ScopeClosed, "}", 0, 0, line}
+func scopeClosedToken() Token {
+	return Token{Type: ScopeClosed, Content: "}"}
 }
 
-func intToken(str string, val int, line int) Token {
-	return Token{Integer, str, val, 0, line}
+func intToken(str string, val int) Token {
+	return Token{Type: Integer, Content: str, ValueInt: val}
 }
 
-func floatToken(str string, val float64, line int) Token {
-	return Token{Float, str, 0, val, line}
+func floatToken(str string, val float64) Token {
+	return Token{Type: Float, Content: str, ValueFloat: val}
 }
 
-func stringToken(content string, line int) Token {
-	return Token{String, content, 0, 0, line}
+func stringToken(content string) Token {
+	return Token{Type: String, Content: content}
 }
 
-func identifierToken(id string, line int) Token {
-	return Token{Identifier, id, 0, 0, line}
+func identifierToken(id string) Token {
+	return Token{Type: Identifier, Content: id}
 }
@@ -3,8 +3,25 @@ package tokens
 import (
 	"reflect"
 	"testing"
+
+	"github.com/worldOneo/datapacklang/diag"
 )
 
+// line is the span of a token in the line, TestCodeLexer_Lexer only compares lines.
+func line(line int) Span {
+	return Span{Start: Position{Line: line}}
+}
+
+// lines reduces the spans of the tokens to the line they start in.
+func lines(tokens []Token) []Token {
+	reduced := make([]Token, len(tokens))
+	for i, token := range tokens {
+		reduced[i] = token
+		reduced[i].Span = line(token.Line())
+	}
+	return reduced
+}
+
 func TestCodeLexer_Lexer(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -19,9 +36,9 @@ func TestCodeLexer_Lexer(t *testing.T) {
 			store[test]++
 			`,
 			[]Token{
-				identifierToken("store", 0), {IndexOpen, "[", 0, 0, 0}, identifierToken("test", 0), {IndexClosed, "]", 0, 0, 0}, {OperationAssignment, "=", OperationSet, 0, 0}, {Integer, "1", 1, 0, 0},
-				identifierToken("store", 1), {IndexOpen, "[", 0, 0, 1}, identifierToken("test", 1), {IndexClosed, "]", 0, 0, 1}, {OperationAssignment, "+=", OperationAdd, 0, 1}, {Integer, "1", 1, 0, 1},
-				identifierToken("store", 2), {IndexOpen, "[", 0, 0, 2}, identifierToken("test", 2), {IndexClosed, "]", 0, 0, 2}, {OperationAssignment, "++", OperationInc, 0, 2},
+				{Identifier, "store", 0, 0, line(1), ""}, {IndexOpen, "[", 0, 0, line(1), ""}, {Identifier, "test", 0, 0, line(1), ""}, {IndexClosed, "]", 0, 0, line(1), ""}, {OperationAssignment, "=", OperationSet, 0, line(1), ""}, {Integer, "1", 1, 0, line(1), ""},
+				{Identifier, "store", 0, 0, line(2), ""}, {IndexOpen, "[", 0, 0, line(2), ""}, {Identifier, "test", 0, 0, line(2), ""}, {IndexClosed, "]", 0, 0, line(2), ""}, {OperationAssignment, "+=", OperationAdd, 0, line(2), ""}, {Integer, "1", 1, 0, line(2), ""},
+				{Identifier, "store", 0, 0, line(3), ""}, {IndexOpen, "[", 0, 0, line(3), ""}, {Identifier, "test", 0, 0, line(3), ""}, {IndexClosed, "]", 0, 0, line(3), ""}, {OperationAssignment, "++", OperationInc, 0, line(3), ""},
 			},
 			false,
 		},
@@ -29,9 +46,9 @@ func TestCodeLexer_Lexer(t *testing.T) {
 			"calculations",
 			`a[b] = c[d]+1`,
 			[]Token{
-				identifierToken("a", 0), {IndexOpen, "[", 0, 0, 0}, identifierToken("b", 0), {IndexClosed, "]", 0, 0, 0}, {OperationAssignment, "=", OperationSet, 0, 0},
-				identifierToken("c", 0), {IndexOpen, "[", 0, 0, 0}, identifierToken("d", 0), {IndexClosed, "]", 0, 0, 0},
-				{Operation, "+", OperationAdd, 0, 0}, {Integer, "1", 1, 0, 0},
+				{Identifier, "a", 0, 0, line(1), ""}, {IndexOpen, "[", 0, 0, line(1), ""}, {Identifier, "b", 0, 0, line(1), ""}, {IndexClosed, "]", 0, 0, line(1), ""}, {OperationAssignment, "=", OperationSet, 0, line(1), ""},
+				{Identifier, "c", 0, 0, line(1), ""}, {IndexOpen, "[", 0, 0, line(1), ""}, {Identifier, "d", 0, 0, line(1), ""}, {IndexClosed, "]", 0, 0, line(1), ""},
+				{Operation, "+", OperationAdd, 0, line(1), ""}, {Integer, "1", 1, 0, line(1), ""},
 			},
 			false,
 		},
@@ -39,20 +56,67 @@ func TestCodeLexer_Lexer(t *testing.T) {
 			"calculations primitives",
 			`a[b] = 1+2`,
 			[]Token{
-				identifierToken("a", 0), {IndexOpen, "[", 0, 0, 0}, identifierToken("b", 0), {IndexClosed, "]", 0, 0, 0},
-				{OperationAssignment, "=", OperationSet, 0, 0}, {Integer, "1", 1, 0, 0}, {Operation, "+", OperationAdd, 0, 0}, {Integer, "2", 2, 0, 0},
+				{Identifier, "a", 0, 0, line(1), ""}, {IndexOpen, "[", 0, 0, line(1), ""}, {Identifier, "b", 0, 0, line(1), ""}, {IndexClosed, "]", 0, 0, line(1), ""},
+				{OperationAssignment, "=", OperationSet, 0, line(1), ""}, {Integer, "1", 1, 0, line(1), ""}, {Operation, "+", OperationAdd, 0, line(1), ""}, {Integer, "2", 2, 0, line(1), ""},
 			},
 			false,
 		},
 		{
 			"if",
-			"if 1 < 2 { `say hi` }",
+			"if 1 < 2 { 'say hi' }",
+			[]Token{
+				{If, "if", 0, 0, line(1), ""}, {Integer, "1", 1, 0, line(1), ""}, {OperationComp, "<", OperationLt, 0, line(1), ""}, {Integer, "2", 2, 0, line(1), ""},
+				{ScopeOpen, "{", 0, 0, line(1), ""}, {String, "say hi", 0, 0, line(1), ""}, {ScopeClosed, "}", 0, 0, line(1), ""},
+			},
+			false,
+		},
+		{
+			"comparators",
+			`< <= == != >= >`,
+			[]Token{
+				{OperationComp, "<", OperationLt, 0, line(1), ""}, {OperationComp, "<=", OperationLte, 0, line(1), ""},
+				{OperationComp, "==", OperationEq, 0, line(1), ""}, {OperationComp, "!=", OperationNeq, 0, line(1), ""},
+				{OperationComp, ">=", OperationGte, 0, line(1), ""}, {OperationComp, ">", OperationGt, 0, line(1), ""},
+			},
+			false,
+		},
+		{
+			"signs",
+			`a[b] = -1*-(2)`,
+			[]Token{
+				{Identifier, "a", 0, 0, line(1), ""}, {IndexOpen, "[", 0, 0, line(1), ""}, {Identifier, "b", 0, 0, line(1), ""}, {IndexClosed, "]", 0, 0, line(1), ""},
+				{OperationAssignment, "=", OperationSet, 0, line(1), ""}, {Operation, "-", OperationSub, 0, line(1), ""}, {Integer, "1", 1, 0, line(1), ""},
+				{Operation, "*", OperationMul, 0, line(1), ""}, {Operation, "-", OperationSub, 0, line(1), ""},
+				{ParenOpen, "(", 0, 0, line(1), ""}, {Integer, "2", 2, 0, line(1), ""}, {ParenClosed, ")", 0, 0, line(1), ""},
+			},
+			false,
+		},
+		{
+			"comments",
+			"a[b] = 1 // a[b] = 2\n// 'say hi'",
+			[]Token{
+				{Identifier, "a", 0, 0, line(1), ""}, {IndexOpen, "[", 0, 0, line(1), ""}, {Identifier, "b", 0, 0, line(1), ""}, {IndexClosed, "]", 0, 0, line(1), ""},
+				{OperationAssignment, "=", OperationSet, 0, line(1), ""}, {Integer, "1", 1, 0, line(1), ""},
+			},
+			false,
+		},
+		{
+			"ranges",
+			`for i in 0..1_000 s[a]..n..2`,
 			[]Token{
-				{If, "if", 0, 0, 0}, {Integer, "1", 1, 0, 0}, {OperationComp, "<", OperationLt, 0, 0}, {Integer, "2", 2, 0, 0},
-				{ScopeOpen, "{", 0, 0, 0}, {String, "say hi", 0, 0, 0}, {ScopeClosed, "}", 0, 0, 0},
+				{For, "for", 0, 0, line(1), ""}, {Identifier, "i", 0, 0, line(1), ""}, {In, "in", 0, 0, line(1), ""},
+				{Integer, "0", 0, 0, line(1), ""}, {Range, "..", 0, 0, line(1), ""}, {Integer, "1000", 1000, 0, line(1), ""},
+				{Identifier, "s", 0, 0, line(1), ""}, {IndexOpen, "[", 0, 0, line(1), ""}, {Identifier, "a", 0, 0, line(1), ""}, {IndexClosed, "]", 0, 0, line(1), ""},
+				{Range, "..", 0, 0, line(1), ""}, {Identifier, "n", 0, 0, line(1), ""}, {Range, "..", 0, 0, line(1), ""}, {Integer, "2", 2, 0, line(1), ""},
 			},
 			false,
 		},
+		{
+			"floats",
+			`1.5 2_0.2_5`,
+			[]Token{{Float, "1.5", 0, 1.5, line(1), ""}, {Float, "20.25", 0, 20.25, line(1), ""}},
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -61,9 +125,174 @@ func TestCodeLexer_Lexer(t *testing.T) {
 				t.Errorf("WordParser.Parse() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.want) {
+			if !reflect.DeepEqual(lines(got), tt.want) {
 				t.Errorf("WordParser.Parse() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func at(line, column, offset int) Position {
+	return Position{Line: line, Column: column, Offset: offset}
+}
+
+func TestCodeLexer_Spans(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want []Span
+	}{
+		{
+			"columns",
+			"a[b] += 10",
+			[]Span{
+				{Start: at(1, 1, 0), End: at(1, 2, 1)},
+				{Start: at(1, 2, 1), End: at(1, 3, 2)},
+				{Start: at(1, 3, 2), End: at(1, 4, 3)},
+				{Start: at(1, 4, 3), End: at(1, 5, 4)},
+				{Start: at(1, 6, 5), End: at(1, 8, 7)},
+				{Start: at(1, 9, 8), End: at(1, 11, 10)},
+			},
+		},
+		{
+			"crlf",
+			"if\r\n\r\n'say hi'\r\n}",
+			[]Span{
+				{Start: at(1, 1, 0), End: at(1, 3, 2)},
+				{Start: at(3, 1, 6), End: at(3, 9, 14)},
+				{Start: at(4, 1, 16), End: at(4, 2, 17)},
+			},
+		},
+		{
+			"unicode",
+			"'ä' ö..1",
+			[]Span{
+				{Start: at(1, 1, 0), End: at(1, 4, 4)},
+				{Start: at(1, 5, 5), End: at(1, 6, 7)},
+				{Start: at(1, 6, 7), End: at(1, 8, 9)},
+				{Start: at(1, 8, 9), End: at(1, 9, 10)},
+			},
+		},
+		{
+			"multiline string",
+			"'a\nb' c",
+			[]Span{
+				{Start: at(1, 1, 0), End: at(2, 3, 5)},
+				{Start: at(2, 4, 6), End: at(2, 5, 7)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexed, err := Lexer(tt.code)
+			if err != nil {
+				t.Fatalf("Lexer() error = %v", err)
+			}
+			got := make([]Span, len(lexed))
+			for i, token := range lexed {
+				got[i] = token.Span
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lexer() spans = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeLexer_Diagnostics(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		tokens int
+		want   []diag.Diagnostic
+	}{
+		{
+			"invalid numbers",
+			"a = 1.2.3\nb = 99999999999999999999 c",
+			7,
+			[]diag.Diagnostic{
+				diag.Errorf(diag.InvalidNumber, Span{Start: at(1, 5, 4), End: at(1, 10, 9)}, "Invalid float literal 1.2.3"),
+				diag.Errorf(diag.InvalidNumber, Span{Start: at(2, 5, 14), End: at(2, 25, 34)}, "Invalid int literal 99999999999999999999"),
+			},
+		},
+		{
+			"unterminated string",
+			"'say hi\n",
+			1,
+			[]diag.Diagnostic{
+				diag.Errorf(diag.UnterminatedString, Span{Start: at(1, 1, 0), End: at(2, 1, 8)}, "Unterminated string"),
+			},
+		},
+		{
+			"unknown operator",
+			"if ! s[a] == 1",
+			7,
+			[]diag.Diagnostic{
+				diag.Errorf(diag.ExpectedToken, Span{Start: at(1, 4, 3), End: at(1, 5, 4)}, "Unknown operator !"),
+			},
+		},
+		{
+			"unterminated block comment",
+			"a /* b\n",
+			1,
+			[]diag.Diagnostic{
+				diag.Errorf(diag.UnterminatedComment, Span{Start: at(1, 3, 2), End: at(2, 1, 7)}, "Unterminated block comment"),
+			},
+		},
+		{
+			"nested block comment",
+			"/* a /* b */ c */",
+			3,
+			[]diag.Diagnostic{
+				diag.Errorf(diag.NestedComment, Span{Start: at(1, 6, 5), End: at(1, 8, 7)}, "Block comments can't be nested"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexed, err := Lexer(tt.code)
+			if len(lexed) != tt.tokens {
+				t.Errorf("Lexer() = %v, want %d tokens", lexed, tt.tokens)
+			}
+			got, _ := diag.From(err)
+			if !reflect.DeepEqual([]diag.Diagnostic(got), tt.want) {
+				t.Errorf("Lexer() error = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexerComments_Block(t *testing.T) {
+	words, comments, err := LexerComments("a /* one\r\ntwo */ b\n/// doc\n/// more\ncreate")
+	if err != nil {
+		t.Fatalf("LexerComments() error = %v", err)
+	}
+	want := []Comment{
+		{"/* one\r\ntwo */", Span{Start: at(1, 3, 2), End: at(2, 7, 16)}},
+		{"/// doc", Span{Start: at(3, 1, 19), End: at(3, 8, 26)}},
+		{"/// more", Span{Start: at(4, 1, 27), End: at(4, 9, 35)}},
+	}
+	if !reflect.DeepEqual(comments, want) {
+		t.Errorf("LexerComments() comments = %v, want %v", comments, want)
+	}
+	if len(words) != 3 || words[1].Line() != 2 || words[2].Doc != "doc\nmore" || words[1].Doc != "" {
+		t.Errorf("LexerComments() tokens = %v", words)
+	}
+}
+
+func TestLexerComments(t *testing.T) {
+	words, comments, err := LexerComments("// first \r\na[b] = 1 // trailing\n'//not a comment'")
+	if err != nil {
+		t.Fatalf("LexerComments() error = %v", err)
+	}
+	want := []Comment{
+		{"// first", Span{Start: at(1, 1, 0), End: at(1, 9, 8)}},
+		{"// trailing", Span{Start: at(2, 10, 20), End: at(2, 21, 31)}},
+	}
+	if !reflect.DeepEqual(comments, want) {
+		t.Errorf("LexerComments() comments = %v, want %v", comments, want)
+	}
+	if len(words) != 7 || words[6].Content != "//not a comment" {
+		t.Errorf("LexerComments() tokens = %v", words)
+	}
+}
@@ -0,0 +1,87 @@
+package translator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+// comment inserts the comments of the program in front of the first command of the function
+// generated for a later line, a comment is only written once.
+// A comment on the line of a command, like a trailing comment, follows the last command of that line.
+// Functions generated for a statement take the comments from the line of the statement on,
+// the function of the module also takes the remaining comments at its end.
+// The commands have to be marked with their origin.
+func (T *Translator) comment(name string, cmds []command) []command {
+	from := T.position.Line
+	commented := make([]command, 0, len(cmds))
+	for i, cmd := range cmds {
+		line := originLine(cmd)
+		if line > 0 {
+			commented = append(commented, T.take(from, line)...)
+		}
+		commented = append(commented, cmd)
+		if line > 0 && (i == len(cmds)-1 || originLine(cmds[i+1]) != line) {
+			commented = append(commented, T.take(line, line+1)...)
+		}
+	}
+	if name == T.options.Module {
+		commented = append(commented, T.take(from, math.MaxInt32)...)
+	}
+	return commented
+}
+
+// originLine returns the line the command is marked with, 0 if it has no origin.
+func originLine(cmd command) int {
+	marker := strings.Index(cmd, originMarker)
+	if marker < 0 {
+		return 0
+	}
+	line, _ := strconv.Atoi(cmd[marker+len(originMarker):])
+	return line
+}
+
+// take removes the comments from line from up to, but excluding, line to
+// and returns their non empty lines as # lines marked with their origin.
+func (T *Translator) take(from, to int) []command {
+	taken := make([]command, 0)
+	remaining := T.comments[:0]
+	for _, comment := range T.comments {
+		line := comment.Span.Start.Line
+		if line < from || line >= to {
+			remaining = append(remaining, comment)
+			continue
+		}
+		for i, text := range text(comment) {
+			if text == "" {
+				continue
+			}
+			position := comment.Span.Start
+			position.Line += i
+			taken = append(taken, mark(position, []command{strings.TrimSpace("# " + text)})...)
+		}
+	}
+	T.comments = remaining
+	return taken
+}
+
+// text returns the lines of the comment without the comment signs and surrounding spaces.
+func text(comment tokens.Comment) []string {
+	if !strings.HasPrefix(comment.Content, "/*") {
+		return []string{strings.TrimSpace(strings.TrimLeft(comment.Content, "/"))}
+	}
+	content := strings.TrimSuffix(strings.TrimPrefix(comment.Content, "/*"), "*/")
+	content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\r", "\n")
+	lines := strings.Split(content, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines
+}
+
+// Comments sets the comments of the program translated next, see Options.Comments.
+func (T *Translator) Comments(comments []tokens.Comment) {
+	T.comments = append([]tokens.Comment{}, comments...)
+}
@@ -0,0 +1,182 @@
+package translator
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+// resourceName matches the names minecraft accepts for namespaces and the parts of function paths.
+var resourceName = regexp.MustCompile(`^[a-z0-9_.-]+$`)
+
+// declaration is a func of the program which can be called.
+type declaration struct {
+	// name is the path of the compiled function inside the namespace
+	name       string
+	parameters []string
+	returns    bool
+}
+
+// declare makes the func callable before its body is compiled.
+// Funcs are placed with the generated functions of the file declaring them,
+// so funcs of different files and the functions of the files can't collide.
+func (T *Translator) declare(f ast.Func) error {
+	if _, ok := T.funcs[f.Identifier]; ok {
+		return failf(diag.InvalidDeclaration, "Func %s is already declared", f.Identifier)
+	}
+	if !resourceName.MatchString(f.Identifier) {
+		return failf(diag.InvalidDeclaration, "Func name %s may only contain a-z, 0-9, _, . and -", f.Identifier)
+	}
+	name := path.Join(generatedDirectory, T.options.Module, "func_"+f.Identifier)
+	seen := make(map[string]bool)
+	for _, parameter := range f.Parameters {
+		if seen[parameter] {
+			return failf(diag.InvalidDeclaration, "Duplicate parameter %s of func %s", parameter, f.Identifier)
+		}
+		seen[parameter] = true
+	}
+	returns := false
+	if len(f.Body.Body) > 0 {
+		_, returns = f.Body.Body[len(f.Body.Body)-1].(ast.Return)
+	}
+	T.funcs[f.Identifier] = declaration{name, f.Parameters, returns}
+	return nil
+}
+
+// compileFunc translates the body of a declared func into its own function.
+// The body gets its own registers so calling it can't overwrite registers held by the caller.
+func (T *Translator) compileFunc(f ast.Func) error {
+	declared := T.funcs[f.Identifier]
+	registers, locals := T.registers, T.locals
+	T.registers = NewRegisters()
+	T.locals = make(map[string]ast.Node)
+	defer func() {
+		T.registers, T.locals = registers, locals
+	}()
+
+	for _, parameter := range f.Parameters {
+		T.locals[parameter] = T.slot(f.Identifier, parameter)
+	}
+	body := f.Body.Body
+	var returned ast.Return
+	if declared.returns {
+		returned = body[len(body)-1].(ast.Return)
+		body = body[:len(body)-1]
+	}
+	cmds, err := T.translate(ast.Block{Body: body})
+	if err != nil {
+		return err
+	}
+	if declared.returns {
+		T.create(dplInternal)
+		assign, err := T.storeAssign(ast.MakeStoreAssign(dplInternal, returnSlot(f.Identifier), true, tokens.OperationSet, returned.Value))
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, mark(returned.Pos, assign)...)
+	}
+	T.add(declared.name, cmds)
+	return nil
+}
+
+// call passes the arguments through the parameter slots and runs the func.
+// All arguments are evaluated before the first slot is written
+// so arguments calling the same func can't overwrite each other.
+// Arguments reading a slot of the called func, like its parameters in a recursive call,
+// are copied into registers first, otherwise they would read slots which are already written.
+func (T *Translator) call(n ast.Expression) ([]command, error) {
+	declared, ok := T.funcs[n.Identifier]
+	if !ok {
+		return nil, failf(diag.UnknownFunc, "Unknown func %s", n.Identifier)
+	}
+	if len(n.ArgList) != len(declared.parameters) {
+		return nil, failf(diag.ArgumentCount, "Func %s expects %d arguments but got %d", n.Identifier, len(declared.parameters), len(n.ArgList))
+	}
+	T.create(dplInternal)
+	if len(n.ArgList) > 0 {
+		T.create(dplTemp)
+	}
+	cmds := make([]command, 0)
+	values := make([]ast.Node, len(n.ArgList))
+	registers := make([]string, 0)
+	for i, arg := range n.ArgList {
+		if constant, ok := arg.(ast.Int); ok {
+			values[i] = constant
+			continue
+		}
+		access, eval, register, err := T.operand(arg)
+		if err != nil {
+			return nil, err
+		}
+		if register == "" && T.slotOf(n.Identifier, access) {
+			register = T.registers.claim(T)
+			eval, err = T.translate(ast.MakeStoreAssign(dplTemp, register, true, tokens.OperationSet, access))
+			if err != nil {
+				return nil, err
+			}
+			access = ast.MakeStoreAccess(dplTemp, register, true)
+		}
+		cmds = append(cmds, eval...)
+		values[i] = access
+		if register != "" {
+			registers = append(registers, register)
+		}
+	}
+	for i, parameter := range declared.parameters {
+		slot := T.slot(n.Identifier, parameter)
+		assign, err := T.storeAssign(ast.MakeStoreAssign(slot.Store, slot.Identifier.Identifier, true, tokens.OperationSet, values[i]))
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, assign...)
+	}
+	for _, register := range registers {
+		T.registers.free(register)
+	}
+	return append(cmds, fmt.Sprintf(call, T.location(declared.name))), nil
+}
+
+// returned is the slot holding the return value of the last call of the func.
+func (T *Translator) returned(n ast.Expression) (ast.StoreAccess, error) {
+	declared, ok := T.funcs[n.Identifier]
+	if !ok {
+		return ast.StoreAccess{}, failf(diag.UnknownFunc, "Unknown func %s", n.Identifier)
+	}
+	if !declared.returns {
+		return ast.StoreAccess{}, failf(diag.MissingReturn, "Func %s doesn't return a value", n.Identifier)
+	}
+	return ast.MakeStoreAccess(dplInternal, returnSlot(n.Identifier), true), nil
+}
+
+// slot is the score a parameter of a func is passed in.
+// Parentheses can't be part of identifiers so slots never collide with variables of the program.
+func (T *Translator) slot(function, parameter string) ast.StoreAccess {
+	return ast.MakeStoreAccess(dplInternal, function+"("+parameter, true)
+}
+
+// slotOf reports whether the access reads a parameter or the return value of the func.
+func (T *Translator) slotOf(function string, access ast.StoreAccess) bool {
+	return access.Store == dplInternal && strings.HasPrefix(access.Identifier.Identifier, function+"(")
+}
+
+func returnSlot(function string) string {
+	return function + "()"
+}
+
+// resolve replaces a variable by the value it is bound to.
+func (T *Translator) resolve(value ast.Node) (ast.Node, error) {
+	variable, ok := value.(ast.Variable)
+	if !ok {
+		return value, nil
+	}
+	bound, ok := T.locals[variable.Identifier]
+	if !ok {
+		return nil, failf(diag.UnknownVariable, "Unknown variable %s", variable.Identifier)
+	}
+	return bound, nil
+}
@@ -0,0 +1,87 @@
+package translator
+
+import (
+	"fmt"
+	"path"
+)
+
+const (
+	generatedDirectory = "__dpl"
+	loadFunction       = "load"
+	uninstallFunction  = "uninstall"
+)
+
+// Function is a .mcfunction file generated during the translation.
+type Function struct {
+	// Name is the path of the function inside the functions directory of the namespace.
+	Name     string
+	Commands []string
+}
+
+// Functions returns every function generated so far in the order they were generated.
+func (T *Translator) Functions() []Function {
+	return T.functions
+}
+
+// Load returns the function creating every objective used by the translated program.
+// It has to run before any other function, a datapack adds it to the #minecraft:load tag.
+func (T *Translator) Load() Function {
+	return T.load(path.Join(generatedDirectory, T.options.Module, loadFunction))
+}
+
+func (T *Translator) load(name string) Function {
+	cmds := make([]command, 0, len(T.objectives))
+	for _, store := range T.objectives {
+		cmds = append(cmds, fmt.Sprintf(createStorage, T.getStore(store)))
+	}
+	return Function{name, cmds}
+}
+
+// Uninstall returns the function removing every objective allocated during the translation,
+// including the internal ones, in the order they were allocated.
+// It is placed next to the load function, so it can't collide with a file named uninstall.dpl.
+func (T *Translator) Uninstall() Function {
+	return T.uninstall(path.Join(generatedDirectory, T.options.Module, uninstallFunction))
+}
+
+func (T *Translator) uninstall(name string) Function {
+	cmds := make([]command, 0, len(T.allocated))
+	for _, store := range T.allocated {
+		cmds = append(cmds, fmt.Sprintf(removeStorage, store))
+	}
+	return Function{name, cmds}
+}
+
+// function adds a generated function with the commands and returns its resource location.
+func (T *Translator) function(kind string, cmds []command) string {
+	name := T.reserve(kind)
+	T.add(name, cmds)
+	return T.location(name)
+}
+
+// add adds a generated function, commands without origin originate from the statement being translated.
+func (T *Translator) add(name string, cmds []command) {
+	T.functions = append(T.functions, Function{name, T.finish(name, cmds)})
+}
+
+// reserve returns an unused name for a generated function
+// which can be referenced before its commands are known.
+func (T *Translator) reserve(kind string) string {
+	T.nextFunction++
+	return path.Join(generatedDirectory, T.options.Module, fmt.Sprintf("%s_%d", kind, T.nextFunction))
+}
+
+// location is the resource location of a function in the namespace.
+func (T *Translator) location(name string) string {
+	return T.options.Namespace + ":" + name
+}
+
+// block runs the commands behind the prefix.
+// If BlockFunctions is enabled bodies exceeding the InlineThreshold are moved into an own function
+// so the prefix is only evaluated once.
+func (T *Translator) block(prefix string, cmds []command) []command {
+	if !T.options.BlockFunctions || len(cmds) <= T.options.InlineThreshold {
+		return prefixed(prefix, cmds)
+	}
+	return []command{prefix + fmt.Sprintf(call, T.function("block", cmds))}
+}
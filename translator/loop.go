@@ -0,0 +1,163 @@
+package translator
+
+import (
+	"fmt"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+const (
+	ifMatches = "execute if score %s %s matches %d if score %s %s matches %s run "
+	guardHit  = `tellraw @a {"text":"%s stopped after %d iterations","color":"red"}`
+)
+
+// _while lowers the loop to a function which checks the condition
+// and calls the body which calls the loop function again.
+func (T *Translator) _while(n ast.While) ([]command, error) {
+	T.create(dplTemp)
+	cmds := make([]command, 0)
+	temp := T.getStore(dplTemp)
+	loop := T.reserve("while")
+
+	counter := ""
+	if T.options.MaxIterations != 0 {
+		counter = T.registers.claim(T)
+		cmds = append(cmds, fmt.Sprintf(editStorage, storeSet, T.getVariable(counter), temp, 0))
+	}
+
+	check, condition, err := T.condition(n.First, n.Comparator, n.Second, n.Not)
+	if err != nil {
+		return nil, err
+	}
+	flag := T.registers.claim(T)
+	check = append(check, fmt.Sprintf(latch, T.getVariable(flag), temp, condition))
+
+	body, err := T.translate(n.Body)
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, fmt.Sprintf(call, T.location(loop)))
+	run := fmt.Sprintf(call, T.location(loop+"_body"))
+	if counter == "" {
+		check = append(check, fmt.Sprintf(ifFlag, T.getVariable(flag), temp, 1)+run)
+	} else {
+		// The guard is checked before the body runs, so it is reported only once
+		// and not again while the recursion unwinds.
+		max := T.options.MaxIterations
+		if max < 0 {
+			// the two guard commands and the counter aren't added yet
+			max = iterations(len(check)+2, len(body)+1)
+		}
+		hit := fmt.Sprintf(guardHit, T.location(loop), max)
+		check = append(check,
+			fmt.Sprintf(ifMatches, T.getVariable(flag), temp, 1, T.getVariable(counter), temp, fmt.Sprintf("%d..", max))+hit,
+			fmt.Sprintf(ifMatches, T.getVariable(flag), temp, 1, T.getVariable(counter), temp, fmt.Sprintf("..%d", max-1))+run)
+		body = append([]command{fmt.Sprintf(editStorage, storeAdd, T.getVariable(counter), temp, 1)}, body...)
+		T.registers.free(counter)
+	}
+	T.registers.free(flag)
+
+	T.add(loop, check)
+	T.add(loop+"_body", body)
+	return append(cmds, fmt.Sprintf(call, T.location(loop))), nil
+}
+
+// iterations is the limit of a loop whose check and body run the amount of commands per iteration.
+// Half of maxCommandChainLength is left for the commands around the loop,
+// nested loops and functions called by the body aren't counted.
+func iterations(check, body int) int {
+	max := maxCommandChainLength / 2 / (check + body)
+	if max < 1 {
+		return 1
+	}
+	return max
+}
+
+// _for unrolls loops with literal bounds of at most UnrollLimit iterations.
+// Every other loop is lowered to a while loop over a counter held in the temp store,
+// the end is evaluated once before the first iteration.
+func (T *Translator) _for(n ast.For) ([]command, error) {
+	first, err := T.resolve(n.Start)
+	if err != nil {
+		return nil, err
+	}
+	last, err := T.resolve(n.End)
+	if err != nil {
+		return nil, err
+	}
+	start, startLiteral := first.(ast.Int)
+	end, endLiteral := last.(ast.Int)
+	if startLiteral && endLiteral && end.Value-start.Value <= T.options.UnrollLimit {
+		return T.unroll(n.Variable, start.Value, end.Value, n.Body)
+	}
+
+	T.create(dplTemp)
+	cmds := make([]command, 0)
+	counter := T.registers.claim(T)
+	access := ast.MakeStoreAccess(dplTemp, counter, true)
+	init, err := T.storeAssign(ast.MakeStoreAssign(dplTemp, counter, true, tokens.OperationSet, n.Start))
+	if err != nil {
+		return nil, err
+	}
+	cmds = append(cmds, init...)
+
+	var limit ast.Node = end
+	limitRegister := ""
+	if !endLiteral {
+		limitRegister = T.registers.claim(T)
+		eval, err := T.storeAssign(ast.MakeStoreAssign(dplTemp, limitRegister, true, tokens.OperationSet, n.End))
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, eval...)
+		limit = ast.MakeStoreAccess(dplTemp, limitRegister, true)
+	}
+
+	restore := T.bind(n.Variable, access)
+	body := make([]ast.Node, len(n.Body.Body), len(n.Body.Body)+1)
+	copy(body, n.Body.Body)
+	body = append(body, ast.MakeStoreAssign(dplTemp, counter, true, tokens.OperationAdd, ast.Int{Value: 1}))
+	loop, err := T._while(ast.While{First: access, Comparator: tokens.OperationLt, Second: limit, Body: ast.Block{Body: body}})
+	restore()
+	if err != nil {
+		return nil, err
+	}
+	if limitRegister != "" {
+		T.registers.free(limitRegister)
+	}
+	T.registers.free(counter)
+	return append(cmds, loop...), nil
+}
+
+// unroll translates the body once for every value of the variable.
+func (T *Translator) unroll(variable string, start, end int, body ast.Block) ([]command, error) {
+	cmds := make([]command, 0)
+	for i := start; i < end; i++ {
+		restore := T.bind(variable, ast.Int{Value: i})
+		iteration, err := T.translate(body)
+		restore()
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, iteration...)
+	}
+	return cmds, nil
+}
+
+// bind makes the variable refer to the value until restore is called.
+// Anonymous variables aren't bound.
+func (T *Translator) bind(variable string, value ast.Node) (restore func()) {
+	if variable == "" {
+		return func() {}
+	}
+	previous, shadowed := T.locals[variable]
+	T.locals[variable] = value
+	return func() {
+		if shadowed {
+			T.locals[variable] = previous
+		} else {
+			delete(T.locals, variable)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package translator
+
+import (
+	"strings"
+)
+
+// Names maps names of the program to the generated objectives and fake players.
+type Names struct {
+	Stores    map[string]string `json:"stores"`
+	Variables map[string]string `json:"variables"`
+}
+
+// Manifest is the mapping of every name generated during the translation.
+type Manifest struct {
+	Names
+	// Internal are the stores of the translator and the fake players of registers and func slots.
+	Internal Names `json:"internal"`
+}
+
+// Manifest returns the generated names to find the scores of the program in game.
+func (T *Translator) Manifest() Manifest {
+	manifest := Manifest{
+		Names{make(map[string]string), make(map[string]string)},
+		Names{make(map[string]string), make(map[string]string)},
+	}
+	for store, name := range T.stores {
+		if store == dplTemp || store == dplInternal {
+			manifest.Internal.Stores[store] = name
+		} else {
+			manifest.Stores[store] = name
+		}
+	}
+	for variable, name := range T.variables {
+		if strings.Contains(variable, "(") {
+			manifest.Internal.Variables[variable] = name
+		} else {
+			manifest.Variables[variable] = name
+		}
+	}
+	return manifest
+}
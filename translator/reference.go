@@ -0,0 +1,47 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Declaration is a store or func declared by the program.
+type Declaration struct {
+	// Module of the file declaring it, see Options.Module
+	Module string
+	// Kind is either store or func
+	Kind string
+	// Name of the store or of the func followed by its parameters like add(a, b)
+	Name string
+	// Doc is the text of the /// comments in front of the declaration
+	Doc string
+	// Compiled is the objective of a store or the function of a func
+	Compiled string
+}
+
+// Declarations returns the stores and funcs declared by the programs translated so far in order.
+func (T *Translator) Declarations() []Declaration {
+	return T.declarations
+}
+
+// Reference renders the declarations as markdown reference grouped by their module.
+func Reference(declarations []Declaration) string {
+	reference := strings.Builder{}
+	reference.WriteString("# Reference\n")
+	module := ""
+	for i, declaration := range declarations {
+		if i == 0 || declaration.Module != module {
+			module = declaration.Module
+			fmt.Fprintf(&reference, "\n## %s\n", module)
+		}
+		compiled := "Objective"
+		if declaration.Kind == "func" {
+			compiled = "Function"
+		}
+		fmt.Fprintf(&reference, "\n### %s%s `%s`\n%s `%s`\n", strings.ToUpper(declaration.Kind[:1]), declaration.Kind[1:], declaration.Name, compiled, declaration.Compiled)
+		if declaration.Doc != "" {
+			fmt.Fprintf(&reference, "\n%s\n", declaration.Doc)
+		}
+	}
+	return reference.String()
+}
@@ -12,10 +12,12 @@ func NewRegisters() *Registers {
 	}
 }
 
+// claim returns a free register.
+// Like func slots registers start with a parenthesis so they never collide with variables of the program.
 func (R *Registers) claim(T *Translator) string {
 	R.dx--
 	if R.dx < 0 {
-		reg := T.nextIdentifier()
+		reg := "(" + T.nextIdentifier()
 		R.dx++
 		return reg
 	}
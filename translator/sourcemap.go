@@ -0,0 +1,81 @@
+package translator
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+// originMarker separates the line of the statement a command was generated for from the command.
+// The line is attached as suffix while translating, so it survives prefixing and moving commands into functions,
+// and removed once the commands of a function are complete.
+const originMarker = "\x00"
+
+// Origin is the statement of the program a command was generated for.
+type Origin struct {
+	// Module of the file containing the statement, see Options.Module
+	Module string `json:"module"`
+	// Line of the statement starting at 1, 0 if the command wasn't generated for a statement
+	Line int `json:"line"`
+}
+
+// SourceMap contains the origin of every command of the functions by the name of the function.
+type SourceMap map[string][]Origin
+
+// SourceMap returns the origins of the commands of every function translated so far.
+func (T *Translator) SourceMap() SourceMap {
+	return T.origins
+}
+
+// translate translates the node and marks the commands without origin with the position of the node.
+// While a statement is translated its position is the origin of generated functions.
+func (T *Translator) translate(node ast.Node) ([]command, error) {
+	position := ast.PositionOf(node)
+	if position.Line == 0 {
+		return T.statement(node)
+	}
+	outer := T.position
+	T.position = position
+	cmds, err := T.statement(node)
+	T.position = outer
+	if err != nil {
+		return nil, diag.At(err, diag.Point(position))
+	}
+	return mark(position, cmds), nil
+}
+
+// mark attaches the position to the commands which have no origin yet.
+func mark(position tokens.Position, cmds []command) []command {
+	if position.Line == 0 {
+		return cmds
+	}
+	for i, cmd := range cmds {
+		if !strings.Contains(cmd, originMarker) {
+			cmds[i] = cmd + originMarker + strconv.Itoa(position.Line)
+		}
+	}
+	return cmds
+}
+
+// finish removes the origins from the commands of the function and adds them to the source map.
+func (T *Translator) finish(name string, cmds []command) []command {
+	cmds = mark(T.position, cmds)
+	if T.options.Comments {
+		cmds = T.comment(name, cmds)
+	}
+	origins := make([]Origin, len(cmds))
+	for i, cmd := range cmds {
+		origins[i] = Origin{Module: T.options.Module}
+		marker := strings.Index(cmd, originMarker)
+		if marker < 0 {
+			continue
+		}
+		origins[i].Line, _ = strconv.Atoi(cmd[marker+len(originMarker):])
+		cmds[i] = cmd[:marker]
+	}
+	T.origins[name] = origins
+	return cmds
+}
@@ -2,19 +2,26 @@ package translator
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
 	"github.com/worldOneo/datapacklang/tokens"
 )
 
 const (
 	createStorage    = "scoreboard objectives add %s dummy"
+	removeStorage    = "scoreboard objectives remove %s"
 	editStorage      = "scoreboard players %s %s %s %d"
 	storageOperation = "scoreboard players operation %s %s %s %s %s"
 
-	ifScore = "execute %s score %s %s %s %s %s run "
-	as      = "execute as %s run "
-	result  = "execute store result %s %s run %s "
+	scoreCondition = "%s score %s %s %s %s %s"
+	latch          = "execute store success score %s %s %s"
+	ifFlag         = "execute if score %s %s matches %d run "
+	as             = "execute as %s run "
+	call           = "function %s"
+	result         = "execute store result %s %s run %s "
 )
 
 const (
@@ -33,6 +40,10 @@ const (
 var storageAccessOperations = make(map[tokens.OperationType]string)
 var storageAssignOperations = make(map[tokens.OperationType]string)
 var conditionalOperators = make(map[tokens.OperationType]string)
+var negatedOperations = map[tokens.OperationType]tokens.OperationType{
+	tokens.OperationAdd: tokens.OperationSub,
+	tokens.OperationSub: tokens.OperationAdd,
+}
 
 func init() {
 	storageAccessOperations[tokens.OperationAdd] = "+="
@@ -43,11 +54,12 @@ func init() {
 	storageAccessOperations[tokens.OperationDiv] = "/="
 
 	storageAssignOperations[tokens.OperationAdd] = storeAdd
-	storageAssignOperations[tokens.OperationDec] = storeSub
+	storageAssignOperations[tokens.OperationSub] = storeSub
 	storageAssignOperations[tokens.OperationSet] = storeSet
 
 	conditionalOperators[tokens.OperationEq] = "="
-	conditionalOperators[tokens.OperationNeq] = "!="
+	// execute has no !=, it is tested as unless ... =
+	conditionalOperators[tokens.OperationNeq] = "="
 	conditionalOperators[tokens.OperationGt] = ">"
 	conditionalOperators[tokens.OperationGte] = ">="
 	conditionalOperators[tokens.OperationLt] = "<"
@@ -57,156 +69,367 @@ func init() {
 
 type command = string
 
+// Options configure the output of a Translator.
+type Options struct {
+	// Namespace of the datapack the generated functions are placed in.
+	Namespace string
+	// Module is the path of the translated file inside the namespace,
+	// functions generated for it are placed below __dpl/<Module>.
+	Module string
+	// BlockFunctions moves bodies of as, if and scoped blocks into own functions
+	// instead of prefixing every command of the body.
+	BlockFunctions bool
+	// InlineThreshold is the maximum amount of commands a body may have to still be inlined
+	// if BlockFunctions is enabled.
+	InlineThreshold int
+	// MaxIterations stops loops after this many iterations and reports it in the chat.
+	// Loops are unguarded if it is 0, if it is negative the limit is derived from the size of each loop.
+	MaxIterations int
+	// UnrollLimit is the maximum amount of iterations of a for or repeat loop with literal bounds
+	// which is unrolled into straight commands instead of a loop function.
+	UnrollLimit int
+	// Prefix is prepended to the generated objectives as <Prefix>.a
+	// and to the fake players as #<Prefix>_b so they don't collide with other datapacks.
+	Prefix string
+	// Identifiers allocates the generated names.
+	// Translators sharing it never generate the same name, every translator allocates its own if it is nil.
+	Identifiers *Identifiers
+	// DebugNames keeps the names of the source as objectives and fake players if they are valid and unique,
+	// names are only mangled on conflicts.
+	DebugNames bool
+	// Comments writes the comments of the program as # lines in front of the commands
+	// of the statement following them.
+	Comments bool
+}
+
+// maxObjectiveLength is the maximum length of an objective name in minecraft.
+const maxObjectiveLength = 16
+
+// maxPlayerLength is the maximum length of a fake player name in minecraft.
+const maxPlayerLength = 40
+
+// maxPrefixLength leaves room for the 17576 identifiers of up to three letters.
+const maxPrefixLength = maxObjectiveLength - 4
+
+var prefixName = regexp.MustCompile(`^[a-zA-Z0-9_.+-]*$`)
+var invalidName = regexp.MustCompile(`[^a-zA-Z0-9_.+-]`)
+
+// Validate checks that the options produce valid names.
+func (O Options) Validate() error {
+	if !resourceName.MatchString(O.Namespace) {
+		return fmt.Errorf("Namespace %s may only contain a-z, 0-9, _, . and -", O.Namespace)
+	}
+	if !prefixName.MatchString(O.Prefix) {
+		return fmt.Errorf("Prefix %s may only contain a-z, A-Z, 0-9, _, ., + and -", O.Prefix)
+	}
+	if len(O.Prefix) > maxPrefixLength {
+		return fmt.Errorf("Prefix %s is longer than %d characters", O.Prefix, maxPrefixLength)
+	}
+	return nil
+}
+
+// Identifiers allocates the names of objectives and fake players.
+type Identifiers struct {
+	next  int
+	taken map[string]bool
+}
+
+func NewIdentifiers() *Identifiers {
+	return &Identifiers{-1, make(map[string]bool)}
+}
+
+// Next returns a short identifier which isn't taken yet.
+func (I *Identifiers) Next() string {
+	for {
+		I.next++
+		identifier := toString(I.next)
+		if I.take(identifier) {
+			return identifier
+		}
+	}
+}
+
+// take reserves the name and returns false if it was taken already.
+func (I *Identifiers) take(name string) bool {
+	if I.taken[name] {
+		return false
+	}
+	I.taken[name] = true
+	return true
+}
+
+// maxCommandChainLength is the default of the gamerule limiting the commands run by one function call.
+const maxCommandChainLength = 65536
+
+func DefaultOptions() Options {
+	return Options{
+		Namespace:       "dpl",
+		InlineThreshold: 1,
+		MaxIterations:   -1,
+	}
+}
+
 type Translator struct {
-	variables map[string]string
-	stores    map[string]string
-	registers *Registers
-	nextVar   int
+	variables    map[string]string
+	stores       map[string]string
+	registers    *Registers
+	identifiers  *Identifiers
+	options      Options
+	functions    []Function
+	nextFunction int
+	funcs        map[string]declaration
+	locals       map[string]ast.Node
+	objectives   []string
+	created      map[string]bool
+	allocated    []string
+	origins      SourceMap
+	position     tokens.Position
+	// comments of the program which weren't written into a function yet
+	comments     []tokens.Comment
+	declarations []Declaration
 }
 
 func New() Translator {
+	return NewWithOptions(DefaultOptions())
+}
+
+func NewWithOptions(options Options) Translator {
+	identifiers := options.Identifiers
+	if identifiers == nil {
+		identifiers = NewIdentifiers()
+	}
 	return Translator{
 		make(map[string]string),
 		make(map[string]string),
 		NewRegisters(),
-		-1,
+		identifiers,
+		options,
+		make([]Function, 0),
+		0,
+		make(map[string]declaration),
+		make(map[string]ast.Node),
+		make([]string, 0),
+		make(map[string]bool),
+		make([]string, 0),
+		make(SourceMap),
+		tokens.Position{},
+		nil,
+		make([]Declaration, 0),
 	}
 }
 
+// Translate translates the program of the file at Options.Module.
+// The origins of the returned commands are added to the source map under the name of the module.
 func (T *Translator) Translate(program ast.Node) ([]command, error) {
+	cmds, err := T.translate(program)
+	if err != nil {
+		return nil, err
+	}
+	return T.finish(T.options.Module, cmds), nil
+}
+
+func (T *Translator) statement(program ast.Node) ([]command, error) {
 	switch n := program.(type) {
 	case ast.Block:
+		// Statements are translated even after one failed to report every error of the block
 		body := n.Body
 		instructions := make([]command, 0)
-		for _, node := range body {
-			inst, err := T.Translate(node)
-			if err != nil {
-				return []command{}, err
+		diagnostics := make(diag.List, 0)
+		undeclared := make(map[int]bool)
+		for i, node := range body {
+			if f, ok := node.(ast.Func); ok {
+				err := T.declare(f)
+				if err != nil {
+					undeclared[i] = true
+					diagnostics = append(diagnostics, diag.At(err, diag.Point(f.Pos)).(diag.List)...)
+				}
+			}
+		}
+		for i, node := range body {
+			if undeclared[i] {
+				continue
+			}
+			inst, err := T.translate(node)
+			if list, ok := diag.From(err); ok {
+				diagnostics = append(diagnostics, list...)
+				continue
+			} else if err != nil {
+				return nil, err
 			}
 			instructions = append(instructions, inst...)
 		}
+		if len(diagnostics) > 0 {
+			return nil, diagnostics
+		}
 		return instructions, nil
 	case ast.StoreAssign:
 		return T.storeAssign(n)
 	case ast.CreateStore:
-		T.createStore(n.Identifier)
-		return []command{fmt.Sprintf(createStorage, T.getStore(n.Identifier))}, nil
+		T.create(n.Identifier)
+		T.declarations = append(T.declarations, Declaration{T.options.Module, "store", n.Identifier, n.Doc, T.getStore(n.Identifier)})
+		return []command{}, nil
 	case ast.If:
 		return T._if(n)
+	case ast.While:
+		return T._while(n)
+	case ast.For:
+		return T._for(n)
+	case ast.Repeat:
+		return T._for(ast.For{Start: ast.Int{Value: 0}, End: n.Count, Body: n.Body})
 	case ast.String:
 		return []command{n.Value}, nil
+	case ast.Func:
+		name := fmt.Sprintf("%s(%s)", n.Identifier, strings.Join(n.Parameters, ", "))
+		T.declarations = append(T.declarations, Declaration{T.options.Module, "func", name, n.Doc, T.location(T.funcs[n.Identifier].name)})
+		return []command{}, T.compileFunc(n)
+	case ast.Expression:
+		return T.call(n)
+	case ast.Return:
+		return nil, failf(diag.InvalidOperation, "Return must be the last statement of a func")
 	case ast.As:
-		prefix := fmt.Sprintf(as, n.Selector)
-		cmds, err := T.Translate(n.Body)
+		cmds, err := T.translate(n.Body)
 		if err != nil {
 			return nil, err
 		}
-		for i := 0; i < len(cmds); i++ {
-			cmds[i] = prefix + cmds[i]
-		}
-		return cmds, nil
+		return T.block(fmt.Sprintf(as, n.Selector), cmds), nil
 	case ast.Scoped:
-		prefix := n.Prefix + " "
-		cmds, err := T.Translate(n.Body)
+		cmds, err := T.translate(n.Body)
 		if err != nil {
 			return nil, err
 		}
-		for i := 0; i < len(cmds); i++ {
-			cmds[i] = prefix + cmds[i]
-		}
-		return cmds, nil
+		return T.block(n.Prefix+" ", cmds), nil
 	}
 	return []command{}, nil
 }
 
-func (T *Translator) _if(n ast.If) ([]command, error) {
-	cmds := make([]command, 0)
+// failf creates an error diagnostic which is positioned at the statement it occurs in.
+func failf(code diag.Code, format string, args ...interface{}) error {
+	return diag.Errorf(code, diag.Span{}, format, args...)
+}
 
-	operator := storeIf
-	if n.Not {
-		operator = storeNot
+func prefixed(prefix string, cmds []command) []command {
+	for i := 0; i < len(cmds); i++ {
+		cmds[i] = prefix + cmds[i]
 	}
+	return cmds
+}
 
-	a := T.registers.claim(T)
-	b := T.registers.claim(T)
-
-	// Short if optimization
-	var prefix string
-	aAc, okFirst := n.First.(ast.StoreAccess)
-	bAc, okSecond := n.Second.(ast.StoreAccess)
-	if len(n.Body.Body) == 1 && okFirst && okSecond {
-		aV := T.trueName(aAc.Identifier)
-		bV := T.trueName(bAc.Identifier)
-		aS := T.getStore(aAc.Store)
-		bS := T.getStore(bAc.Store)
-		prefix = fmt.Sprintf(ifScore, operator, aV, aS, conditionalOperators[n.Comparator], bV, bS)
-	} else {
-		ok := T.createStore(dplTemp)
-		if !ok {
-			cmd, err := T.Translate(ast.CreateStore{dplTemp})
-			if err != nil {
-				return nil, err
-			}
-			cmds = append(cmds, cmd...)
-		}
-
-		temp := T.getStore(dplTemp)
-		leftRegister := ast.MakeStoreAssign(dplTemp, a, true, tokens.OperationSet, n.First)
-		rightRegister := ast.MakeStoreAssign(dplTemp, b, true, tokens.OperationSet, n.Second)
-		leftEval, err := T.Translate(leftRegister)
-		if err != nil {
-			return nil, err
-		}
-		rightEval, err := T.Translate(rightRegister)
-		if err != nil {
-			return nil, err
-		}
-		cmds = append(cmds, leftEval...)
-		cmds = append(cmds, rightEval...)
-		aV := T.getVariable(a)
-		bV := T.getVariable(b)
-
-		prefix = fmt.Sprintf(ifScore, operator, aV, temp, conditionalOperators[n.Comparator], bV, temp)
+// _if evaluates the condition once into a flag before any branch runs,
+// so changing the compared scores inside a branch doesn't affect the following commands.
+// The flag is shared by every call of a func, a recursive call inside a branch overwrites it.
+func (T *Translator) _if(n ast.If) ([]command, error) {
+	T.create(dplTemp)
+	cmds := make([]command, 0)
+	temp := T.getStore(dplTemp)
+	eval, condition, err := T.condition(n.First, n.Comparator, n.Second, n.Not)
+	if err != nil {
+		return nil, err
+	}
+	flag := T.registers.claim(T)
+	body, err := T.translate(n.Body)
+	if err != nil {
+		return nil, err
 	}
-	for _, elem := range n.Body.Body {
-		commands, err := T.Translate(elem)
+	otherwise := []command{}
+	if n.Else != nil {
+		otherwise, err = T.translate(n.Else)
 		if err != nil {
 			return nil, err
 		}
-		for i := 0; i < len(commands); i++ {
-			commands[i] = prefix + commands[i]
-		}
-		cmds = append(cmds, commands...)
 	}
-	T.registers.free(a)
-	T.registers.free(b)
+	T.registers.free(flag)
+
+	// Short if optimization, a single command evaluates the condition only once anyway
+	if len(eval) == 0 && len(body) == 1 && n.Else == nil {
+		return append(cmds, "execute "+condition+" run "+body[0]), nil
+	}
+	cmds = append(cmds, eval...)
+	cmds = append(cmds, fmt.Sprintf(latch, T.getVariable(flag), temp, condition))
+	cmds = append(cmds, T.block(fmt.Sprintf(ifFlag, T.getVariable(flag), temp, 1), body)...)
+	cmds = append(cmds, T.block(fmt.Sprintf(ifFlag, T.getVariable(flag), temp, 0), otherwise)...)
 	return cmds, nil
 }
 
-func (T *Translator) resolveCalculation(n ast.Calculation) ([]command, ast.StoreAccess, error) {
-	cmds := make([]command, 0)
-	ok := T.createStore(dplTemp)
+// condition evaluates the operands of a comparison and returns the execute subcommand testing it.
+// != is negated into an equality test, so not != tests for equality again.
+// The temp store has to be created already.
+func (T *Translator) condition(first ast.Node, comparator tokens.OperationType, second ast.Node, not bool) ([]command, string, error) {
+	symbol, ok := conditionalOperators[comparator]
 	if !ok {
-		cmd, err := T.Translate(ast.CreateStore{dplTemp})
-		if err != nil {
-			return nil, ast.StoreAccess{}, err
+		return nil, "", failf(diag.InvalidOperation, "Invalid comparator")
+	}
+	left, leftEval, leftRegister, err := T.operand(first)
+	if err != nil {
+		return nil, "", err
+	}
+	right, rightEval, rightRegister, err := T.operand(second)
+	if err != nil {
+		return nil, "", err
+	}
+	cmds := append(leftEval, rightEval...)
+
+	operator := storeIf
+	if not != (comparator == tokens.OperationNeq) {
+		operator = storeNot
+	}
+	condition := fmt.Sprintf(scoreCondition, operator,
+		T.trueName(left.Identifier), T.getStore(left.Store),
+		symbol,
+		T.trueName(right.Identifier), T.getStore(right.Store))
+	for _, register := range []string{leftRegister, rightRegister} {
+		if register != "" {
+			T.registers.free(register)
 		}
-		cmds = append(cmds, cmd...)
 	}
+	return cmds, condition, nil
+}
+
+// operand makes a value comparable by a score condition.
+// Store accesses are used directly, every other value is evaluated into a register
+// which is returned to be freed by the caller.
+func (T *Translator) operand(value ast.Node) (ast.StoreAccess, []command, string, error) {
+	value, err := T.resolve(value)
+	if err != nil {
+		return ast.StoreAccess{}, nil, "", err
+	}
+	if access, ok := value.(ast.StoreAccess); ok {
+		return access, []command{}, "", nil
+	}
+	register := T.registers.claim(T)
+	cmds, err := T.translate(ast.MakeStoreAssign(dplTemp, register, true, tokens.OperationSet, value))
+	if err != nil {
+		return ast.StoreAccess{}, nil, "", err
+	}
+	return ast.MakeStoreAccess(dplTemp, register, true), cmds, register, nil
+}
+
+// create adds the store to the objectives created by the load function.
+func (T *Translator) create(store string) {
+	T.createStore(store)
+	if T.created[store] {
+		return
+	}
+	T.created[store] = true
+	T.objectives = append(T.objectives, store)
+}
+
+func (T *Translator) resolveCalculation(n ast.Calculation) ([]command, ast.StoreAccess, error) {
+	T.create(dplTemp)
+	cmds := make([]command, 0)
 	a := T.registers.claim(T)
 	b := T.registers.claim(T)
 	initRegister := ast.MakeStoreAssign(dplTemp, a, true, tokens.OperationSet, n.First)
 	calcRegister := ast.MakeStoreAssign(dplTemp, b, true, tokens.OperationSet, n.Second)
 	operations := ast.MakeStoreAssign(dplTemp, a, true, n.Operator, ast.MakeStoreAccess(dplTemp, b, true))
-	init, err := T.Translate(initRegister)
+	init, err := T.translate(initRegister)
 	if err != nil {
 		return nil, ast.StoreAccess{}, err
 	}
-	calc, err := T.Translate(calcRegister)
+	calc, err := T.translate(calcRegister)
 	if err != nil {
 		return nil, ast.StoreAccess{}, err
 	}
-	op, err := T.Translate(operations)
+	op, err := T.translate(operations)
 	if err != nil {
 		return nil, ast.StoreAccess{}, err
 	}
@@ -218,30 +441,58 @@ func (T *Translator) resolveCalculation(n ast.Calculation) ([]command, ast.Store
 	return cmds, ast.MakeStoreAccess(dplTemp, a, true), nil
 }
 
+// constantOperation applies an operation without a constant form (*=, /=, %=)
+// by loading the constant into a register first.
+func (T *Translator) constantOperation(n ast.StoreAssign, value ast.Int) ([]command, error) {
+	if _, ok := storageAccessOperations[n.Operation]; !ok {
+		return nil, failf(diag.InvalidOperation, "Invalid operator")
+	}
+	T.create(dplTemp)
+	cmds := make([]command, 0)
+	a := T.registers.claim(T)
+	load, err := T.translate(ast.MakeStoreAssign(dplTemp, a, true, tokens.OperationSet, value))
+	if err != nil {
+		return nil, err
+	}
+	apply, err := T.storeAssign(ast.MakeStoreAssign(n.Store, n.Identifier.Identifier, n.Identifier.IsVar, n.Operation, ast.MakeStoreAccess(dplTemp, a, true)))
+	if err != nil {
+		return nil, err
+	}
+	T.registers.free(a)
+	cmds = append(cmds, load...)
+	cmds = append(cmds, apply...)
+	return cmds, nil
+}
+
 func (T *Translator) storeAssign(n ast.StoreAssign) ([]command, error) {
 	store := T.getStore(n.Store)
 	variable := T.getVariable(n.Identifier.Identifier)
 	if !n.Identifier.IsVar {
 		variable = n.Identifier.Identifier
 	}
-	value := n.Value
+	value, err := T.resolve(n.Value)
+	if err != nil {
+		return nil, err
+	}
 	switch v := value.(type) {
 	case ast.Int:
-		op, ok := storageAssignOperations[n.Operation]
+		operation, amount := n.Operation, v.Value
+		// add and remove only accept positive amounts
+		if amount < 0 && (operation == tokens.OperationAdd || operation == tokens.OperationSub) {
+			operation, amount = negatedOperations[operation], -amount
+		}
+		op, ok := storageAssignOperations[operation]
 		if !ok {
-			return nil, fmt.Errorf("Invalid operator")
+			return T.constantOperation(n, v)
 		}
-		return []command{fmt.Sprintf(editStorage, op, variable, store, v.Value)}, nil
+		return []command{fmt.Sprintf(editStorage, op, variable, store, amount)}, nil
 	case ast.StoreAccess:
 		op, ok := storageAccessOperations[n.Operation]
 		if !ok {
-			return nil, fmt.Errorf("Invalid operator")
+			return nil, failf(diag.InvalidOperation, "Invalid operator")
 		}
 		withStore := T.getStore(v.Store)
-		withVar := T.getVariable(v.Identifier.Identifier)
-		if !n.Identifier.IsVar {
-			withVar = n.Identifier.Identifier
-		}
+		withVar := T.trueName(v.Identifier)
 		return []command{fmt.Sprintf(storageOperation, variable, store, op, withVar, withStore)}, nil
 	case ast.Calculation:
 		cmds, access, err := T.resolveCalculation(v)
@@ -254,16 +505,35 @@ func (T *Translator) storeAssign(n ast.StoreAssign) ([]command, error) {
 		}
 		cmds = append(cmds, assign...)
 		return cmds, nil
+	case ast.Expression:
+		cmds, err := T.call(v)
+		if err != nil {
+			return nil, err
+		}
+		returned, err := T.returned(v)
+		if err != nil {
+			return nil, err
+		}
+		assign, err := T.storeAssign(ast.MakeStoreAssign(n.Store, n.Identifier.Identifier, n.Identifier.IsVar, n.Operation, returned))
+		if err != nil {
+			return nil, err
+		}
+		return append(cmds, assign...), nil
 	case ast.String:
 		return []command{fmt.Sprintf(result, variable, store, v.Value)}, nil
 	}
-	return nil, fmt.Errorf("Invalid assignment")
+	return nil, failf(diag.InvalidOperation, "Invalid assignment")
 }
 
 func (T *Translator) getStore(key string) string {
 	_, ok := T.stores[key]
 	if !ok {
-		T.stores[key] = T.nextIdentifier()
+		limit := maxObjectiveLength
+		if T.options.Prefix != "" {
+			limit -= len(T.options.Prefix) + 1
+		}
+		T.stores[key] = T.objective(T.name(key, limit))
+		T.allocated = append(T.allocated, T.stores[key])
 	}
 	return T.stores[key]
 }
@@ -271,7 +541,11 @@ func (T *Translator) getStore(key string) string {
 func (T *Translator) getVariable(variable string) string {
 	v, ok := T.variables[variable]
 	if !ok {
-		v = T.nextIdentifier()
+		limit := maxPlayerLength
+		if T.options.Prefix != "" {
+			limit -= len(T.options.Prefix) + 2
+		}
+		v = T.player(T.name(variable, limit))
 		T.variables[variable] = v
 	}
 	return v
@@ -279,9 +553,7 @@ func (T *Translator) getVariable(variable string) string {
 
 func (T *Translator) createStore(variable string) bool {
 	_, ok := T.stores[variable]
-	if !ok {
-		T.stores[variable] = T.nextIdentifier()
-	}
+	T.getStore(variable)
 	return ok
 }
 
@@ -292,9 +564,40 @@ func toString(i int) string {
 	return toString((i/26)-1) + string('a'+(rune(i)%26))
 }
 
+// name keeps the source name if DebugNames is enabled and the name isn't taken yet.
+// Characters minecraft doesn't allow are replaced by _ and the name is cut to the limit.
+func (T *Translator) name(source string, limit int) string {
+	if T.options.DebugNames {
+		name := invalidName.ReplaceAllString(source, "_")
+		if len(name) > limit {
+			name = name[:limit]
+		}
+		if name != "" && T.identifiers.take(name) {
+			return name
+		}
+	}
+	return T.nextIdentifier()
+}
+
 func (T *Translator) nextIdentifier() string {
-	T.nextVar++
-	return toString(T.nextVar)
+	return T.identifiers.Next()
+}
+
+// objective is the name of the objective with the identifier.
+func (T *Translator) objective(identifier string) string {
+	if T.options.Prefix == "" {
+		return identifier
+	}
+	return T.options.Prefix + "." + identifier
+}
+
+// player is the name of the fake player with the identifier.
+// The # hides it from the sidebar.
+func (T *Translator) player(identifier string) string {
+	if T.options.Prefix == "" {
+		return identifier
+	}
+	return "#" + T.options.Prefix + "_" + identifier
 }
 
 func (T *Translator) trueName(index ast.Index) string {
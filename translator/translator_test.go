@@ -0,0 +1,1276 @@
+package translator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+// machine executes the subset of commands the translator emits
+// to observe the behaviour of the generated functions.
+type machine struct {
+	scores     map[string]map[string]int
+	objectives map[string]bool
+	functions  map[string][]command
+	said       []string
+	told       []string
+	executed   int
+}
+
+var errChainLength = errors.New("maxCommandChainLength exceeded")
+
+// newMachine loads the functions of the translator and runs its load function.
+func newMachine(T *Translator) (*machine, error) {
+	M := &machine{
+		scores:     make(map[string]map[string]int),
+		objectives: make(map[string]bool),
+		functions:  make(map[string][]command),
+		said:       make([]string, 0),
+		told:       make([]string, 0),
+	}
+	for _, function := range T.Functions() {
+		M.functions[T.options.Namespace+":"+function.Name] = function.Commands
+	}
+	return M, M.run(T.Load().Commands)
+}
+
+func (M *machine) run(cmds []command) error {
+	for _, cmd := range cmds {
+		M.executed++
+		if M.executed > maxCommandChainLength {
+			return errChainLength
+		}
+		if _, err := M.exec(strings.Fields(cmd)); err == errChainLength {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", cmd, err)
+		}
+	}
+	return nil
+}
+
+func (M *machine) score(player, objective string) (int, bool) {
+	v, ok := M.scores[objective][player]
+	return v, ok
+}
+
+func (M *machine) set(player, objective string, value int) error {
+	if !M.objectives[objective] {
+		return fmt.Errorf("unknown objective %s", objective)
+	}
+	M.scores[objective][player] = value
+	return nil
+}
+
+// exec runs a single command and returns whether it succeeded.
+func (M *machine) exec(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, fmt.Errorf("empty command")
+	}
+	switch args[0] {
+	case "say":
+		M.said = append(M.said, strings.Join(args[1:], " "))
+		return true, nil
+	case "tellraw":
+		M.told = append(M.told, strings.Join(args[2:], " "))
+		return true, nil
+	case "scoreboard":
+		return true, M.scoreboard(args[1:])
+	case "execute":
+		return M.execute(args[1:])
+	case "function":
+		function, ok := M.functions[args[1]]
+		if !ok {
+			return false, fmt.Errorf("unknown function %s", args[1])
+		}
+		return true, M.run(function)
+	}
+	return false, fmt.Errorf("unknown command %s", args[0])
+}
+
+func (M *machine) scoreboard(args []string) error {
+	switch {
+	case len(args) == 4 && args[0] == "objectives" && args[1] == "add":
+		M.objectives[args[2]] = true
+		if _, ok := M.scores[args[2]]; !ok {
+			M.scores[args[2]] = make(map[string]int)
+		}
+		return nil
+	case len(args) == 3 && args[0] == "objectives" && args[1] == "remove":
+		if !M.objectives[args[2]] {
+			return fmt.Errorf("unknown objective %s", args[2])
+		}
+		delete(M.objectives, args[2])
+		delete(M.scores, args[2])
+		return nil
+	case len(args) == 5 && args[0] == "players":
+		value, err := strconv.Atoi(args[4])
+		if err != nil {
+			return err
+		}
+		current, _ := M.score(args[2], args[3])
+		switch args[1] {
+		case "set":
+			return M.set(args[2], args[3], value)
+		case "add", "remove":
+			if value < 0 {
+				return fmt.Errorf("negative amount %d", value)
+			}
+			if args[1] == "remove" {
+				value = -value
+			}
+			return M.set(args[2], args[3], current+value)
+		}
+	case len(args) == 7 && args[0] == "players" && args[1] == "operation":
+		source, ok := M.score(args[5], args[6])
+		if !ok {
+			return fmt.Errorf("unset score %s %s", args[5], args[6])
+		}
+		target, _ := M.score(args[2], args[3])
+		switch args[4] {
+		case "=":
+			target = source
+		case "+=":
+			target += source
+		case "-=":
+			target -= source
+		case "*=":
+			target *= source
+		case "/=":
+			target = floorDiv(target, source)
+		case "%=":
+			target -= floorDiv(target, source) * source
+		default:
+			return fmt.Errorf("unknown operation %s", args[4])
+		}
+		return M.set(args[2], args[3], target)
+	}
+	return fmt.Errorf("unknown scoreboard command %v", args)
+}
+
+func floorDiv(a, b int) int {
+	if b == 0 {
+		return a
+	}
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func (M *machine) execute(args []string) (bool, error) {
+	store := false
+	storePlayer, storeObjective := "", ""
+	result := func(ok bool) (bool, error) {
+		if !store {
+			return ok, nil
+		}
+		return ok, M.storeResult(storePlayer, storeObjective, ok)
+	}
+	for len(args) > 0 {
+		switch args[0] {
+		case "run":
+			ok, err := M.exec(args[1:])
+			if err != nil {
+				return false, err
+			}
+			return result(ok)
+		case "store":
+			if len(args) < 5 || args[1] != "success" || args[2] != "score" {
+				return false, fmt.Errorf("invalid store %v", args)
+			}
+			store, storePlayer, storeObjective = true, args[3], args[4]
+			args = args[5:]
+		case "if", "unless":
+			if len(args) < 6 || args[1] != "score" {
+				return false, fmt.Errorf("invalid condition %v", args)
+			}
+			var holds bool
+			var err error
+			expected := args[0] == "if"
+			if args[4] == "matches" {
+				holds, err = M.matches(args[2], args[3], args[5])
+				args = args[6:]
+			} else if len(args) >= 7 {
+				holds, err = M.compare(args[2], args[3], args[4], args[5], args[6])
+				args = args[7:]
+			} else {
+				err = fmt.Errorf("invalid condition %v", args)
+			}
+			if err != nil {
+				return false, err
+			}
+			if holds != expected {
+				return result(false)
+			}
+		case "as", "at":
+			args = args[2:]
+		default:
+			return false, fmt.Errorf("unknown execute subcommand %s", args[0])
+		}
+	}
+	return result(true)
+}
+
+func (M *machine) matches(player, objective, matchRange string) (bool, error) {
+	value, ok := M.score(player, objective)
+	if !ok {
+		return false, nil
+	}
+	low, high := matchRange, matchRange
+	if i := strings.Index(matchRange, ".."); i >= 0 {
+		low, high = matchRange[:i], matchRange[i+2:]
+	}
+	if low != "" {
+		bound, err := strconv.Atoi(low)
+		if err != nil || value < bound {
+			return false, err
+		}
+	}
+	if high != "" {
+		bound, err := strconv.Atoi(high)
+		if err != nil || value > bound {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (M *machine) compare(player, objective, operator, otherPlayer, otherObjective string) (bool, error) {
+	a, ok := M.score(player, objective)
+	if !ok {
+		return false, nil
+	}
+	b, ok := M.score(otherPlayer, otherObjective)
+	if !ok {
+		return false, nil
+	}
+	switch operator {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case "=":
+		return a == b, nil
+	case ">=":
+		return a >= b, nil
+	case ">":
+		return a > b, nil
+	}
+	return false, fmt.Errorf("unknown comparator %s", operator)
+}
+
+func (M *machine) storeResult(player, objective string, ok bool) error {
+	value := 0
+	if ok {
+		value = 1
+	}
+	return M.set(player, objective, value)
+}
+
+func TestTranslator_Translate(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			"calculations",
+			`create store s
+			s[a] = 10*10+10
+			s[b] = -(s[a] - 20) / 4 % 7
+			s[b] *= 3
+			s[b] -= -1
+			if s[a] == 110 { 'say a' }
+			if s[b] == 16 { 'say b' }`,
+			[]string{"a", "b"},
+			false,
+		},
+		{
+			"readme",
+			`create store someStore
+
+			someStore[someVar] = 100
+
+			if someStore[someVar] == 100 {
+				'say counter reached 100'
+				'say will be reseted now'
+				someStore[someVar] = 2 - 2
+				if someStore[someVar] == 0 {
+					'say someVar reseted'
+				}
+				'say done'
+			}`,
+			[]string{"counter reached 100", "will be reseted now", "someVar reseted", "done"},
+			false,
+		},
+		{
+			"condition changed by body",
+			`create store s
+			s[x] = 1
+			s[y] = 1
+			if s[x] == s[y] {
+				'execute as @s run' {
+					s[x] += 1
+					'say still running'
+				}
+			}
+			if s[x] > s[y] {
+				s[x] = s[y]
+				'say not skipped'
+			}`,
+			[]string{"still running", "not skipped"},
+			false,
+		},
+		{
+			"else",
+			`create store s
+			s[x] = 1
+			if s[x] == 1 {
+				'say then'
+				s[x] = 0
+			} else {
+				'say else'
+			}`,
+			[]string{"then"},
+			false,
+		},
+		{
+			"else if",
+			`create store s
+			s[x] = 2
+			if s[x] == 1 {
+				'say one'
+			} else if s[x] == 2 {
+				'say two'
+				s[x] = 3
+			} else if s[x] == 3 {
+				'say three'
+			} else {
+				'say other'
+			}
+			if not s[x] < 3 {
+				'say still three'
+			} else {
+				'say less'
+			}`,
+			[]string{"two", "still three"},
+			false,
+		},
+		{
+			"nested else",
+			`create store s
+			s[x] = 5
+			if s[x] > 2 {
+				if s[x] > 10 {
+					'say huge'
+				} else {
+					'say big'
+					s[x] = 0
+				}
+			} else {
+				'say small'
+			}`,
+			[]string{"big"},
+			false,
+		},
+		{
+			"func",
+			`create store s
+			greet()
+			func add(a, b) {
+				return a + b
+			}
+			func greet() { 'say hello' }
+			func twice(x) { return add(x, x) }
+			func max(a, b) {
+				if a > b {
+					s[max] = a
+				} else {
+					s[max] = b
+				}
+				return s[max]
+			}
+			s[x] = add(1, 2)
+			if s[x] == 3 { 'say three' }
+			s[y] = add(s[x], add(2, 3)) * 2
+			if s[y] == 16 { 'say sixteen' }
+			if twice(s[x]) == 6 { 'say six' }
+			if max(s[x], -s[y]) + max(4, s[x]) == 7 { 'say seven' }
+			func swap(a, b) {
+				s[depth] += 1
+				if s[depth] == 1 { swap(b, a) }
+				if s[depth] == 2 {
+					s[first] = a
+					s[second] = b
+					s[depth] = 3
+				}
+			}
+			s[depth] = 0
+			swap(1, 2)
+			if s[first] * 10 + s[second] == 21 { 'say swapped' }
+			func shift(a, b) {
+				s[depth] += 1
+				if s[depth] == 1 { shift(1, a) }
+				if s[depth] == 2 {
+					s[shifted] = a * 10 + b
+					s[depth] = 3
+				}
+			}
+			s[depth] = 0
+			shift(5, 7)
+			if s[shifted] == 15 { 'say shifted' }`,
+			[]string{"hello", "three", "sixteen", "six", "seven", "swapped", "shifted"},
+			false,
+		},
+		{
+			"while",
+			`create store s
+			s[i] = 0
+			while s[i] < 3 {
+				s[i]++
+				'say tick'
+			}
+			while not s[i] == 0 {
+				s[j] = 0
+				while s[j] < s[i] {
+					'say tock'
+					s[j]++
+				}
+				s[i]--
+			}
+			func count(n) {
+				s[c] = n
+				while s[c] > 0 {
+					'say down'
+					s[c]--
+				}
+			}
+			count(2)
+			while s[i] > 0 {
+				'say never'
+			}`,
+			[]string{"tick", "tick", "tick", "tock", "tock", "tock", "tock", "tock", "tock", "down", "down"},
+			false,
+		},
+		{
+			"for",
+			`create store s
+			s[n] = 3
+			s[sum] = 0
+			for i in 0..5 { s[sum] += i }
+			if s[sum] == 10 { 'say ten' }
+			for i in 1..s[n] {
+				for j in 0..i { 'say x' }
+				'say y'
+			}
+			for i in 0..2 {
+				for j in i..2 { 'say z' }
+			}
+			repeat 2 { 'say rep' }
+			repeat s[n] { s[sum]++ }
+			if s[sum] == 13 { 'say thirteen' }
+			for i in 3..1 { 'say never' }
+			for i in -2..-s[n] { 'say never' }`,
+			[]string{"ten", "x", "y", "x", "x", "y", "z", "z", "z", "rep", "rep", "thirteen"},
+			false,
+		},
+		{
+			"unknown func",
+			`missing()`,
+			nil,
+			true,
+		},
+		{
+			"func arguments",
+			`func add(a, b) { return a + b }
+			add(1)`,
+			nil,
+			true,
+		},
+		{
+			"not equal",
+			`create store s
+			s[a] = 1
+			if s[a] != 2 { 'say ne' }
+			if s[a] != 1 { 'say wrong' }
+			if not s[a] != 1 { 'say eq' } else { 'say wrong' }
+			while s[a] != 4 { s[a] += 1 }
+			if s[a] == 4 { 'say looped' }`,
+			[]string{"ne", "eq", "looped"},
+			false,
+		},
+		{
+			"func without return value",
+			`create store s
+			func greet() { 'say hello' }
+			s[x] = greet()`,
+			nil,
+			true,
+		},
+		{
+			"return before end of func",
+			`func f(a) {
+				return a
+				'say hi'
+			}`,
+			nil,
+			true,
+		},
+		{
+			"invalid func name",
+			`func Greet() { 'say hello' }`,
+			nil,
+			true,
+		},
+		{
+			"return outside func",
+			`return 1`,
+			nil,
+			true,
+		},
+		{
+			"unknown variable",
+			`create store s
+			s[x] = y`,
+			nil,
+			true,
+		},
+	}
+	blocks := DefaultOptions()
+	blocks.BlockFunctions = true
+	blocks.InlineThreshold = 0
+	unrolled := DefaultOptions()
+	unrolled.UnrollLimit = 16
+	prefixed := DefaultOptions()
+	prefixed.Prefix = "pack"
+	debug := DefaultOptions()
+	debug.DebugNames = true
+	variants := map[string]Options{
+		"debug":    debug,
+		"inline":   DefaultOptions(),
+		"blocks":   blocks,
+		"unrolled": unrolled,
+		"prefixed": prefixed,
+	}
+	for variant, options := range variants {
+		for _, tt := range tests {
+			t.Run(variant+" "+tt.name, func(t *testing.T) {
+				program, err := ast.Parse(tokens.Lexerp(tt.code))
+				if err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				translator := NewWithOptions(options)
+				cmds, err := translator.Translate(program)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("Translate() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+				if err != nil {
+					return
+				}
+				M, err := newMachine(&translator)
+				if err != nil {
+					t.Fatalf("load error = %v", err)
+				}
+				if err := M.run(cmds); err != nil {
+					t.Fatalf("run() error = %v", err)
+				}
+				if !reflect.DeepEqual(M.said, tt.want) {
+					t.Errorf("Translate() said %v, want %v\n%s", M.said, tt.want, strings.Join(cmds, "\n"))
+				}
+			})
+		}
+	}
+}
+
+func TestTranslator_BlockFunctions(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		code      string
+		want      []string
+		functions []Function
+	}{
+		{
+			"inlined",
+			2,
+			`as '@a' { 'say a' 'say b' }`,
+			[]string{"execute as @a run say a", "execute as @a run say b"},
+			[]Function{},
+		},
+		{
+			"extracted",
+			1,
+			`as '@a' { 'say a' 'say b' }`,
+			[]string{"execute as @a run function pack:__dpl/main/block_1"},
+			[]Function{{"__dpl/main/block_1", []string{"say a", "say b"}}},
+		},
+		{
+			"nested",
+			1,
+			`'execute at @s run' { 'say a' as '@a' { 'say b' 'say c' } }`,
+			[]string{"execute at @s run function pack:__dpl/main/block_2"},
+			[]Function{
+				{"__dpl/main/block_1", []string{"say b", "say c"}},
+				{"__dpl/main/block_2", []string{"say a", "execute as @a run function pack:__dpl/main/block_1"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.Namespace = "pack"
+			options.Module = "main"
+			options.BlockFunctions = true
+			options.InlineThreshold = tt.threshold
+			translator := NewWithOptions(options)
+			program, err := ast.Parse(tokens.Lexerp(tt.code))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := translator.Translate(program)
+			if err != nil {
+				t.Fatalf("Translate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Translate() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(translator.Functions(), tt.functions) {
+				t.Errorf("Functions() = %v, want %v", translator.Functions(), tt.functions)
+			}
+		})
+	}
+}
+
+func TestTranslator_LoopGuard(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxIterations int
+		code          string
+		want          []string
+		wantTold      int
+		wantErr       bool
+	}{
+		{
+			"guarded",
+			100,
+			`create store s
+			s[i] = 0
+			while 1 == 1 { s[i]++ }
+			if s[i] == 100 { 'say stopped' }`,
+			[]string{"stopped"},
+			1,
+			false,
+		},
+		{
+			"default guard",
+			-1,
+			`create store s
+			s[i] = 0
+			s[j] = 0
+			while 1 == 1 {
+				s[i]++
+				s[j] = s[i] * 2 + s[j] % 7 - 1
+				s[k] = s[j] / 3
+			}
+			if s[i] > 1000 { 'say stopped' }`,
+			[]string{"stopped"},
+			1,
+			false,
+		},
+		{
+			"default guard of a large body",
+			-1,
+			"create store s\ns[i] = 0\nwhile 1 == 1 {\n" + strings.Repeat("s[i]++\n", 100) + "}\nif s[i] > 10000 { 'say stopped' }",
+			[]string{"stopped"},
+			1,
+			false,
+		},
+		{
+			"reset between runs",
+			3,
+			`create store s
+			s[i] = 0
+			while s[i] < 3 {
+				s[j] = 0
+				while s[j] < 3 { s[j]++ }
+				s[i]++
+				'say outer'
+			}`,
+			[]string{"outer", "outer", "outer"},
+			0,
+			false,
+		},
+		{
+			"unguarded",
+			0,
+			`while 1 == 1 { 'say forever' }`,
+			nil,
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.MaxIterations = tt.maxIterations
+			translator := NewWithOptions(options)
+			program, err := ast.Parse(tokens.Lexerp(tt.code))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			cmds, err := translator.Translate(program)
+			if err != nil {
+				t.Fatalf("Translate() error = %v", err)
+			}
+			M, err := newMachine(&translator)
+			if err != nil {
+				t.Fatalf("load error = %v", err)
+			}
+			err = M.run(cmds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(M.said, tt.want) {
+				t.Errorf("run() said %v, want %v", M.said, tt.want)
+			}
+			if len(M.told) != tt.wantTold {
+				t.Errorf("run() told %v, want %d messages", M.told, tt.wantTold)
+			}
+		})
+	}
+}
+
+func TestTranslator_Unroll(t *testing.T) {
+	options := DefaultOptions()
+	options.UnrollLimit = 2
+	translator := NewWithOptions(options)
+	program, err := ast.Parse(tokens.Lexerp(`
+		create store s
+		for i in 0..2 { s[x] = i }
+		repeat 3 { 'say looped' }
+	`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := translator.Translate(program)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	want := []string{
+		"scoreboard players set b a 0",
+		"scoreboard players set b a 1",
+	}
+	if !reflect.DeepEqual(got[:2], want) {
+		t.Errorf("Translate() = %v, want prefix %v", got, want)
+	}
+	if len(translator.Functions()) != 2 {
+		t.Errorf("Functions() = %v, want only the repeat loop", translator.Functions())
+	}
+}
+
+// TestTranslator_Comparators compiles every comparator the lexer knows with and without not
+// and checks the outcome of the commands for operands below, equal to and above each other.
+func TestTranslator_Comparators(t *testing.T) {
+	comparators := map[string]func(a, b int) bool{
+		"<":  func(a, b int) bool { return a < b },
+		"<=": func(a, b int) bool { return a <= b },
+		"==": func(a, b int) bool { return a == b },
+		"!=": func(a, b int) bool { return a != b },
+		">=": func(a, b int) bool { return a >= b },
+		">":  func(a, b int) bool { return a > b },
+	}
+	for comparator, holds := range comparators {
+		for _, not := range []string{"", "not "} {
+			for a := 1; a <= 3; a++ {
+				code := fmt.Sprintf("create store s\ns[a] = %d\nif %ss[a] %s 2 { 'say yes' } else { 'say no' }", a, not, comparator)
+				program, err := ast.Parse(tokens.Lexerp(code))
+				if err != nil {
+					t.Fatalf("Parse(%s) error = %v", code, err)
+				}
+				translator := New()
+				cmds, err := translator.Translate(program)
+				if err != nil {
+					t.Fatalf("Translate(%s) error = %v", code, err)
+				}
+				M, err := newMachine(&translator)
+				if err != nil {
+					t.Fatalf("load error = %v", err)
+				}
+				if err := M.run(cmds); err != nil {
+					t.Fatalf("run(%s) error = %v", code, err)
+				}
+				want := []string{"yes"}
+				if holds(a, 2) == (not != "") {
+					want = []string{"no"}
+				}
+				if !reflect.DeepEqual(M.said, want) {
+					t.Errorf("%s said %v, want %v\n%s", code, M.said, want, strings.Join(cmds, "\n"))
+				}
+			}
+		}
+	}
+
+	translator := New()
+	_, err := translator.Translate(ast.Block{Body: []ast.Node{
+		ast.If{First: ast.Int{Value: 1}, Comparator: tokens.OperationAdd, Second: ast.Int{Value: 2}},
+	}})
+	if list, ok := diag.From(err); !ok || list[0].Code != diag.InvalidOperation {
+		t.Errorf("Translate() error = %v, want invalid comparator", err)
+	}
+}
+
+func TestTranslator_Load(t *testing.T) {
+	options := DefaultOptions()
+	options.Module = "main"
+	translator := NewWithOptions(options)
+	program, err := ast.Parse(tokens.Lexerp(`
+		create store s
+		create store t
+		if s[x] > t[x] { 'say greater' 'say done' }
+		create store s
+	`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := translator.Translate(program)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	for _, cmd := range got {
+		if strings.HasPrefix(cmd, "scoreboard objectives") {
+			t.Errorf("Translate() = %v, want no objective setup", got)
+		}
+	}
+	want := Function{"__dpl/main/load", []string{
+		"scoreboard objectives add a dummy",
+		"scoreboard objectives add b dummy",
+		"scoreboard objectives add c dummy",
+	}}
+	if !reflect.DeepEqual(translator.Load(), want) {
+		t.Errorf("Load() = %v, want %v", translator.Load(), want)
+	}
+}
+
+func TestTranslator_Uninstall(t *testing.T) {
+	options := DefaultOptions()
+	options.Module = "main"
+	translator := NewWithOptions(options)
+	program, err := ast.Parse(tokens.Lexerp(`
+		create store s
+		func twice(x) { return x * 2 }
+		s[y] = 1
+		s[y] = twice(s[y] + 1)
+		while s[y] < 10 { s[y]++ }
+	`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	cmds, err := translator.Translate(program)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	M, err := newMachine(&translator)
+	if err != nil {
+		t.Fatalf("load error = %v", err)
+	}
+	if err := M.run(cmds); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	uninstall := translator.Uninstall()
+	if uninstall.Name != "__dpl/main/uninstall" {
+		t.Errorf("Uninstall() name = %s, want __dpl/main/uninstall", uninstall.Name)
+	}
+	if err := M.run(uninstall.Commands); err != nil {
+		t.Fatalf("uninstall error = %v", err)
+	}
+	if len(M.objectives) != 0 {
+		t.Errorf("objectives after uninstall = %v, want none", M.objectives)
+	}
+}
+
+func TestTranslator_Prefix(t *testing.T) {
+	options := DefaultOptions()
+	options.Prefix = "pack"
+	options.Identifiers = NewIdentifiers()
+	first := NewWithOptions(options)
+	second := NewWithOptions(options)
+	program, err := ast.Parse(tokens.Lexerp(`create store s s[x] = 1`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	got, err := first.Translate(program)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	want := []string{"scoreboard players set #pack_b pack.a 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Translate() = %v, want %v", got, want)
+	}
+	got, err = second.Translate(program)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	want = []string{"scoreboard players set #pack_d pack.c 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Translate() of second file = %v, want %v", got, want)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{"", false},
+		{"my_pack.v2", false},
+		{"twelve_chars", false},
+		{"thirteen_char", true},
+		{"my pack", true},
+		{"pack:a", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			options := DefaultOptions()
+			options.Prefix = tt.prefix
+			if err := options.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	for namespace, wantErr := range map[string]bool{"my_pack.v2": false, "MyPack": true, "my pack": true, "": true} {
+		options := DefaultOptions()
+		options.Namespace = namespace
+		if err := options.Validate(); (err != nil) != wantErr {
+			t.Errorf("Validate() of namespace %q error = %v, wantErr %v", namespace, err, wantErr)
+		}
+	}
+}
+
+func TestUnit_Translate(t *testing.T) {
+	unit := NewUnit(DefaultOptions())
+	files := []struct {
+		module string
+		code   string
+	}{
+		{"init", `create store s s[x] = 1 func f() { 'say f' } f()`},
+		{"tick", `create store s s[x] += 1 if s[x] == 2 { 'say shared' } func f() { 'say own f' } f()`},
+	}
+	M, err := newMachine(&unit.translator)
+	if err != nil {
+		t.Fatalf("load error = %v", err)
+	}
+	for _, file := range files {
+		program, err := ast.Parse(tokens.Lexerp(file.code))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		cmds, err := unit.Translate(file.module, program, nil)
+		if err != nil {
+			t.Fatalf("Translate() error = %v", err)
+		}
+		M.functions["dpl:"+file.module] = cmds
+	}
+	for _, function := range unit.Functions() {
+		M.functions["dpl:"+function.Name] = function.Commands
+	}
+	load := unit.Load()
+	if load.Name != "__dpl/load" {
+		t.Errorf("Load() name = %s, want __dpl/load", load.Name)
+	}
+	if uninstall := unit.Uninstall(); uninstall.Name != "__dpl/uninstall" {
+		t.Errorf("Uninstall() name = %s, want __dpl/uninstall", uninstall.Name)
+	}
+	if err := M.run(append(load.Commands, "function dpl:init", "function dpl:tick")); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	want := []string{"f", "shared", "own f"}
+	if !reflect.DeepEqual(M.said, want) {
+		t.Errorf("run() said %v, want %v", M.said, want)
+	}
+
+	program, err := ast.Parse(tokens.Lexerp(`f()`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := unit.Translate("other", program, nil); err == nil {
+		t.Errorf("Translate() calling a func of another file error = nil, want error")
+	}
+	for _, module := range []string{"My File", "lib/My", "lib//a"} {
+		_, err := unit.Translate(module, ast.Block{}, nil)
+		if list, _ := diag.From(err); len(list) != 1 || list[0].Code != diag.InvalidDeclaration {
+			t.Errorf("Translate() of module %s error = %v, want invalid declaration", module, err)
+		}
+	}
+}
+
+func TestTranslator_DebugNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		code   string
+		want   []string
+	}{
+		{
+			"source names",
+			"",
+			`create store someStore someStore[someVar] = 1`,
+			[]string{"scoreboard players set someVar someStore 1"},
+		},
+		{
+			"truncated",
+			"",
+			`create store averyveryverylongstore averyveryverylongstore[x] = 1`,
+			[]string{"scoreboard players set x averyveryverylon 1"},
+		},
+		{
+			"prefixed",
+			"pack",
+			`create store someStore someStore[someVar] = 1`,
+			[]string{"scoreboard players set #pack_someVar pack.someStore 1"},
+		},
+		{
+			"conflict",
+			"",
+			`create store averyveryverylongstoreone create store averyveryverylongstoretwo
+			averyveryverylongstoreone[x] = 1 averyveryverylongstoretwo[x] = 2`,
+			[]string{"scoreboard players set x averyveryverylon 1", "scoreboard players set x a 2"},
+		},
+		{
+			"sanitized",
+			"",
+			`func f(x) { 'say f' } f(1)`,
+			[]string{"scoreboard players set f_x _dpl_internal 1", "function dpl:__dpl/func_f"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.DebugNames = true
+			options.Prefix = tt.prefix
+			translator := NewWithOptions(options)
+			program, err := ast.Parse(tokens.Lexerp(tt.code))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := translator.Translate(program)
+			if err != nil {
+				t.Fatalf("Translate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Translate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslator_Manifest(t *testing.T) {
+	options := DefaultOptions()
+	options.Prefix = "pack"
+	translator := NewWithOptions(options)
+	program, err := ast.Parse(tokens.Lexerp(`
+		create store someStore
+		func f(x) { 'say f' }
+		someStore[someVar] = 1 + 2
+		f(1)
+	`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := translator.Translate(program); err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	want := Manifest{
+		Names{
+			map[string]string{"someStore": "pack.a"},
+			map[string]string{"someVar": "#pack_b"},
+		},
+		Names{
+			map[string]string{"_dpl_tmp": "pack.c", "_dpl_internal": "pack.h"},
+			map[string]string{"(d": "#pack_f", "(e": "#pack_g", "f(x": "#pack_i"},
+		},
+	}
+	if got := translator.Manifest(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Manifest() = %v, want %v", got, want)
+	}
+}
+
+func TestTranslator_SourceMap(t *testing.T) {
+	options := DefaultOptions()
+	options.Module = "main"
+	options.BlockFunctions = true
+	translator := NewWithOptions(options)
+	program, err := ast.Parse(tokens.Lexerp(`create store s
+s[x] = 1 + 2
+if s[x] == 3 {
+	'say a'
+	'say b'
+}
+func f() {
+	'say f'
+	return 1
+}
+s[y] = f()`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	cmds, err := translator.Translate(program)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	for _, cmd := range cmds {
+		if strings.Contains(cmd, originMarker) {
+			t.Errorf("Translate() = %q, want commands without origin", cmd)
+		}
+	}
+	lines := func(origins []Origin) []int {
+		lines := make([]int, len(origins))
+		for i, origin := range origins {
+			if origin.Module != "main" {
+				t.Errorf("origin module = %s, want main", origin.Module)
+			}
+			lines[i] = origin.Line
+		}
+		return lines
+	}
+	want := map[string][]int{
+		"main":               {2, 2, 2, 2, 3, 3, 3, 11, 11},
+		"__dpl/main/block_1": {4, 5},
+		"__dpl/main/func_f":  {8, 9},
+	}
+	sourceMap := translator.SourceMap()
+	if len(sourceMap) != len(want) {
+		t.Errorf("SourceMap() = %v, want functions of %v", sourceMap, want)
+	}
+	for name, origins := range sourceMap {
+		if got := lines(origins); !reflect.DeepEqual(got, want[name]) {
+			t.Errorf("SourceMap()[%s] = %v, want %v", name, got, want[name])
+		}
+	}
+	if len(sourceMap["main"]) != len(cmds) {
+		t.Errorf("SourceMap()[main] has %d origins for %d commands", len(sourceMap["main"]), len(cmds))
+	}
+}
+
+func TestTranslator_Diagnostics(t *testing.T) {
+	translator := New()
+	program, err := ast.Parse(tokens.Lexerp(`create store s
+s[x] = f()
+func g(a, a) { }
+if s[x] == 1 {
+	s[y] = y
+}
+return 1`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	_, err = translator.Translate(program)
+	got, ok := diag.From(err)
+	if !ok {
+		t.Fatalf("Translate() error = %v, want diagnostics", err)
+	}
+	want := []struct {
+		code diag.Code
+		line int
+	}{
+		{diag.InvalidDeclaration, 3},
+		{diag.UnknownFunc, 2},
+		{diag.UnknownVariable, 5},
+		{diag.InvalidOperation, 7},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Translate() error = %v, want %d diagnostics", got, len(want))
+	}
+	for i, diagnostic := range got {
+		if diagnostic.Code != want[i].code || diagnostic.Span.Start.Line != want[i].line {
+			t.Errorf("diagnostic %d = %v, want %s in line %d", i, diagnostic, want[i].code, want[i].line)
+		}
+	}
+}
+
+func TestTranslator_Comments(t *testing.T) {
+	options := DefaultOptions()
+	options.Comments = true
+	options.BlockFunctions = true
+	unit := NewUnit(options)
+	lexed, comments, err := tokens.LexerComments(`// header
+/* block
+   comment */
+'say a' // trailing
+as '@a' {
+	// inside
+	'say b'
+	'say c' // last
+}
+// end`)
+	if err != nil {
+		t.Fatalf("LexerComments() error = %v", err)
+	}
+	program, err := ast.Parse(lexed)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	cmds, err := unit.Translate("main", program, comments)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	want := []string{"# header", "# block", "# comment", "say a", "# trailing", "execute as @a run function dpl:__dpl/main/block_1", "# end"}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("Translate() = %v, want %v", cmds, want)
+	}
+	block := unit.Functions()[0]
+	if want := []string{"# inside", "say b", "say c", "# last"}; !reflect.DeepEqual(block.Commands, want) {
+		t.Errorf("Translate() block = %v, want %v", block.Commands, want)
+	}
+	if want := []Origin{{"main", 6}, {"main", 7}, {"main", 8}, {"main", 8}}; !reflect.DeepEqual(unit.SourceMap()[block.Name], want) {
+		t.Errorf("SourceMap() block = %v, want %v", unit.SourceMap()[block.Name], want)
+	}
+}
+
+func TestReference(t *testing.T) {
+	unit := NewUnit(DefaultOptions())
+	files := []struct {
+		module string
+		code   string
+	}{
+		{"main", "/// Kills of every player\n/// since the start\ncreate store kills\ncreate store tmp"},
+		{"lib/math", "/// Adds two numbers\nfunc add(a, b) {\n\treturn a + b\n}"},
+	}
+	for _, file := range files {
+		program, err := ast.Parse(tokens.Lexerp(file.code))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if _, err := unit.Translate(file.module, program, nil); err != nil {
+			t.Fatalf("Translate() error = %v", err)
+		}
+	}
+	kills := unit.Manifest().Stores["kills"]
+	want := []Declaration{
+		{"main", "store", "kills", "Kills of every player\nsince the start", kills},
+		{"main", "store", "tmp", "", unit.Manifest().Stores["tmp"]},
+		{"lib/math", "func", "add(a, b)", "Adds two numbers", "dpl:__dpl/lib/math/func_add"},
+	}
+	if got := unit.Declarations(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Declarations() = %v, want %v", got, want)
+	}
+	reference := Reference([]Declaration{want[0], want[2]})
+	wantReference := "# Reference\n\n## main\n\n### Store `kills`\nObjective `" + kills + "`\n\nKills of every player\nsince the start\n" +
+		"\n## lib/math\n\n### Func `add(a, b)`\nFunction `dpl:__dpl/lib/math/func_add`\n\nAdds two numbers\n"
+	if reference != wantReference {
+		t.Errorf("Reference() = %q, want %q", reference, wantReference)
+	}
+}
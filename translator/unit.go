@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"path"
+	"strings"
+
+	"github.com/worldOneo/datapacklang/ast"
+	"github.com/worldOneo/datapacklang/diag"
+	"github.com/worldOneo/datapacklang/tokens"
+)
+
+// Unit translates every file of one datapack.
+// Stores and variables have the same names in every file of the unit.
+type Unit struct {
+	translator Translator
+}
+
+func NewUnit(options Options) *Unit {
+	return &Unit{NewWithOptions(options)}
+}
+
+// Translate translates the program of the file which is placed at module inside the namespace.
+// Funcs are only visible in the file declaring them.
+// Every file gets its own registers, so a file calling another one can't overwrite registers it holds.
+// The comments of the file are only used if Options.Comments is set.
+// Every directory and the name of the file have to be valid in a function path.
+func (U *Unit) Translate(module string, program ast.Node, comments []tokens.Comment) ([]command, error) {
+	for _, segment := range strings.Split(module, "/") {
+		if !resourceName.MatchString(segment) {
+			return nil, failf(diag.InvalidDeclaration, "File name %s may only contain a-z, 0-9, _, . and -", segment)
+		}
+	}
+	T := &U.translator
+	T.options.Module = module
+	T.funcs = make(map[string]declaration)
+	T.locals = make(map[string]ast.Node)
+	T.registers = NewRegisters()
+	T.Comments(comments)
+	return T.Translate(program)
+}
+
+// Functions returns every function generated for the files so far.
+func (U *Unit) Functions() []Function {
+	return U.translator.Functions()
+}
+
+// Load returns the function creating the objectives of every file.
+func (U *Unit) Load() Function {
+	return U.translator.load(path.Join(generatedDirectory, loadFunction))
+}
+
+// Uninstall returns the function removing the objectives of every file.
+func (U *Unit) Uninstall() Function {
+	return U.translator.uninstall(path.Join(generatedDirectory, uninstallFunction))
+}
+
+// Manifest returns the generated names of every file.
+func (U *Unit) Manifest() Manifest {
+	return U.translator.Manifest()
+}
+
+// Declarations returns the stores and funcs declared by every file.
+func (U *Unit) Declarations() []Declaration {
+	return U.translator.Declarations()
+}
+
+// SourceMap returns the origins of the commands of every file.
+func (U *Unit) SourceMap() SourceMap {
+	return U.translator.SourceMap()
+}